@@ -0,0 +1,267 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/engine/common/rpc/convert"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"github.com/onflow/flow/protobuf/go/flow/execution"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sync"
+)
+
+// AccessBackend reads transactions from an access node
+// and registers from an execution node, using the Flow Access and Execution APIs.
+type AccessBackend struct {
+	accessClient    access.AccessAPIClient
+	executionClient execution.ExecutionAPIClient
+	conns           []*grpc.ClientConn
+	chain           flow.Chain
+
+	// block IDs the registers are read at, by block height
+	mu       sync.Mutex
+	blockIDs map[uint64]flow.Identifier
+
+	log zerolog.Logger
+}
+
+var _ Backend = &AccessBackend{}
+
+func NewAccessBackend(
+	accessHost string,
+	executionHost string,
+	chain flow.Chain,
+	log zerolog.Logger,
+) (*AccessBackend, error) {
+	accessConn, err := dial(accessHost, log)
+	if err != nil {
+		return nil, err
+	}
+	executionConn, err := dial(executionHost, log)
+	if err != nil {
+		_ = accessConn.Close()
+		return nil, err
+	}
+
+	return &AccessBackend{
+		accessClient:    access.NewAccessAPIClient(accessConn),
+		executionClient: execution.NewExecutionAPIClient(executionConn),
+		conns:           []*grpc.ClientConn{accessConn, executionConn},
+		chain:           chain,
+		blockIDs:        make(map[uint64]flow.Identifier),
+		log:             log,
+	}, nil
+}
+
+func dial(host string, log zerolog.Logger) (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial(
+		host,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("host", host).
+			Msg("Could not connect to server.")
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (b *AccessBackend) GetTransaction(ctx context.Context, txID flow.Identifier) (*flow.TransactionBody, error) {
+	resp, err := b.accessClient.GetTransaction(ctx, &access.GetTransactionRequest{
+		Id: txID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction.")
+		return nil, err
+	}
+	txBody, err := convert.MessageToTransaction(resp.Transaction, b.chain)
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not convert transaction.")
+		return nil, err
+	}
+	return &txBody, nil
+}
+
+func (b *AccessBackend) GetBlockHeader(ctx context.Context, blockHeight uint64) (*flow.Header, error) {
+	resp, err := b.accessClient.GetBlockHeaderByHeight(ctx, &access.GetBlockHeaderByHeightRequest{
+		Height: blockHeight,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight).
+			Msg("Could not get block header.")
+		return nil, err
+	}
+	header, err := convert.MessageToBlockHeader(resp.Block)
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not convert block header.")
+		return nil, err
+	}
+	return header, nil
+}
+
+func (b *AccessBackend) GetBlockTransactions(ctx context.Context, blockHeight uint64) ([]*flow.TransactionBody, error) {
+	header, err := b.GetBlockHeader(ctx, blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	blockID := header.ID()
+
+	resp, err := b.accessClient.GetTransactionsByBlockID(ctx, &access.GetTransactionsByBlockIDRequest{
+		BlockId: blockID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight).
+			Msg("Could not get block transactions.")
+		return nil, err
+	}
+
+	messages := resp.Transactions
+	// access nodes add the system chunk transaction to the end of the block transactions,
+	// it has no payer, so it is dropped before it fails the address validation of the conversion
+	if len(messages) > 0 && isSystemChunkTransactionMessage(messages[len(messages)-1]) {
+		messages = messages[:len(messages)-1]
+	}
+
+	txBodies := make([]*flow.TransactionBody, 0, len(messages))
+	for _, message := range messages {
+		txBody, err := convert.MessageToTransaction(message, b.chain)
+		if err != nil {
+			b.log.Error().
+				Err(err).
+				Msg("Could not convert transaction.")
+			return nil, err
+		}
+		txBodies = append(txBodies, &txBody)
+	}
+	return withoutSystemChunkTransaction(txBodies, b.chain), nil
+}
+
+// isSystemChunkTransactionMessage returns true if the transaction has no payer and no signatures,
+// which only the system chunk transaction can have.
+func isSystemChunkTransactionMessage(message *entities.Transaction) bool {
+	return flow.BytesToAddress(message.Payer) == flow.EmptyAddress && len(message.EnvelopeSignatures) == 0
+}
+
+func (b *AccessBackend) GetTransactionBlockHeight(ctx context.Context, txID flow.Identifier) (uint64, error) {
+	resp, err := b.accessClient.GetTransactionResult(ctx, &access.GetTransactionRequest{
+		Id: txID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction result.")
+		return 0, err
+	}
+	if resp.BlockHeight != 0 {
+		return resp.BlockHeight, nil
+	}
+
+	// older access nodes do not return the block height
+	header, err := b.accessClient.GetBlockHeaderByID(ctx, &access.GetBlockHeaderByIDRequest{
+		Id: resp.BlockId,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction block header.")
+		return 0, err
+	}
+	return header.Block.Height, nil
+}
+
+func (b *AccessBackend) GetTransactionEvents(ctx context.Context, txID flow.Identifier) ([]flow.Event, error) {
+	resp, err := b.accessClient.GetTransactionResult(ctx, &access.GetTransactionRequest{
+		Id: txID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction result.")
+		return nil, err
+	}
+	return convert.MessagesToEvents(resp.Events), nil
+}
+
+// GetRegisterValues reads the registers at the parent of the block at blockHeight,
+// which is the state at the start of the block.
+// The execution API only supports reading one register per request.
+func (b *AccessBackend) GetRegisterValues(
+	ctx context.Context,
+	blockHeight uint64,
+	keys []registers.RegisterKey,
+) ([]flow.RegisterValue, error) {
+	blockID, err := b.stateBlockID(ctx, blockHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]flow.RegisterValue, 0, len(keys))
+	for _, key := range keys {
+		resp, err := b.executionClient.GetRegisterAtBlockID(ctx, &execution.GetRegisterAtBlockIDRequest{
+			BlockId:       blockID[:],
+			RegisterOwner: []byte(key.Owner),
+			RegisterKey:   []byte(key.Key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, resp.Value)
+	}
+	return values, nil
+}
+
+// stateBlockID returns the ID of the block whose execution state is the state at the start of the block at blockHeight.
+func (b *AccessBackend) stateBlockID(ctx context.Context, blockHeight uint64) (flow.Identifier, error) {
+	if blockHeight == 0 {
+		return flow.ZeroID, fmt.Errorf("there is no state before the root block")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockID, ok := b.blockIDs[blockHeight]
+	if ok {
+		return blockID, nil
+	}
+
+	resp, err := b.accessClient.GetBlockHeaderByHeight(ctx, &access.GetBlockHeaderByHeightRequest{
+		Height: blockHeight - 1,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight-1).
+			Msg("Could not get block header.")
+		return flow.ZeroID, err
+	}
+	blockID = convert.MessageToIdentifier(resp.Block.Id)
+	b.blockIDs[blockHeight] = blockID
+	return blockID, nil
+}
+
+func (b *AccessBackend) Close() error {
+	var err error
+	for _, conn := range b.conns {
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package backends
+
+import (
+	"context"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/fvm/blueprints"
+	"github.com/onflow/flow-go/model/flow"
+	"io"
+)
+
+// Backend is the source of the chain data needed to replay a transaction.
+type Backend interface {
+	io.Closer
+
+	// GetTransaction returns the body of the transaction.
+	GetTransaction(ctx context.Context, txID flow.Identifier) (*flow.TransactionBody, error)
+	// GetBlockHeader returns the header of the block at blockHeight.
+	GetBlockHeader(ctx context.Context, blockHeight uint64) (*flow.Header, error)
+	// GetBlockTransactions returns the user transactions of the block at blockHeight, in execution order.
+	// The system chunk transaction is not included.
+	GetBlockTransactions(ctx context.Context, blockHeight uint64) ([]*flow.TransactionBody, error)
+	// GetTransactionBlockHeight returns the height of the block the transaction was executed in.
+	GetTransactionBlockHeight(ctx context.Context, txID flow.Identifier) (uint64, error)
+	// GetTransactionEvents returns the events the network recorded for the transaction, in emission order.
+	GetTransactionEvents(ctx context.Context, txID flow.Identifier) ([]flow.Event, error)
+	// GetRegisterValues returns the register values at the start of the block at blockHeight.
+	// The values are returned in the same order as the keys.
+	GetRegisterValues(ctx context.Context, blockHeight uint64, keys []registers.RegisterKey) ([]flow.RegisterValue, error)
+}
+
+// withoutSystemChunkTransaction returns the block transactions without the system chunk transaction,
+// which some APIs return as the last transaction of the block.
+func withoutSystemChunkTransaction(txBodies []*flow.TransactionBody, chain flow.Chain) []*flow.TransactionBody {
+	if len(txBodies) == 0 || !isSystemChunkTransaction(txBodies[len(txBodies)-1], chain) {
+		return txBodies
+	}
+	return txBodies[:len(txBodies)-1]
+}
+
+// isSystemChunkTransaction returns true if the transaction is the system chunk transaction of the chain.
+// The system chunk transaction script changes between versions, so a transaction without a payer
+// and without signatures, which a user transaction can not be, is recognized as well.
+func isSystemChunkTransaction(txBody *flow.TransactionBody, chain flow.Chain) bool {
+	if txBody.Payer == flow.EmptyAddress && len(txBody.EnvelopeSignatures) == 0 {
+		return true
+	}
+	systemTx, err := blueprints.SystemChunkTransaction(chain)
+	return err == nil && txBody.ID() == systemTx.ID()
+}
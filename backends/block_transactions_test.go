@@ -0,0 +1,138 @@
+package backends
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-dps/api/dps"
+	"github.com/onflow/flow-dps/codec/zbor"
+	"github.com/onflow/flow-go/engine/common/rpc/convert"
+	"github.com/onflow/flow-go/fvm/blueprints"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// fakeAccessClient serves the block transactions like an access node,
+// with the system chunk transaction added to the end.
+type fakeAccessClient struct {
+	access.AccessAPIClient
+
+	header   *flow.Header
+	txBodies []*flow.TransactionBody
+}
+
+func (c *fakeAccessClient) GetBlockHeaderByHeight(_ context.Context, _ *access.GetBlockHeaderByHeightRequest, _ ...grpc.CallOption) (*access.BlockHeaderResponse, error) {
+	message, err := convert.BlockHeaderToMessage(c.header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &access.BlockHeaderResponse{Block: message}, nil
+}
+
+func (c *fakeAccessClient) GetTransactionsByBlockID(_ context.Context, _ *access.GetTransactionsByBlockIDRequest, _ ...grpc.CallOption) (*access.TransactionsResponse, error) {
+	resp := &access.TransactionsResponse{}
+	for _, txBody := range c.txBodies {
+		resp.Transactions = append(resp.Transactions, convert.TransactionToMessage(*txBody))
+	}
+	return resp, nil
+}
+
+// fakeDPSClient serves the block transactions like a DPS archive node.
+type fakeDPSClient struct {
+	dps.APIClient
+
+	codec    *zbor.Codec
+	txBodies []*flow.TransactionBody
+}
+
+func (c *fakeDPSClient) ListTransactionsForHeight(_ context.Context, in *dps.ListTransactionsForHeightRequest, _ ...grpc.CallOption) (*dps.ListTransactionsForHeightResponse, error) {
+	resp := &dps.ListTransactionsForHeightResponse{Height: in.Height}
+	for _, txBody := range c.txBodies {
+		txID := txBody.ID()
+		resp.TransactionIDs = append(resp.TransactionIDs, txID[:])
+	}
+	return resp, nil
+}
+
+func (c *fakeDPSClient) GetTransaction(_ context.Context, in *dps.GetTransactionRequest, _ ...grpc.CallOption) (*dps.GetTransactionResponse, error) {
+	for _, txBody := range c.txBodies {
+		txID := txBody.ID()
+		if flow.HashToID(in.TransactionID) != txID {
+			continue
+		}
+		data, err := c.codec.Marshal(txBody)
+		if err != nil {
+			return nil, err
+		}
+		return &dps.GetTransactionResponse{TransactionID: in.TransactionID, Data: data}, nil
+	}
+	return nil, nil
+}
+
+func userTransaction(chain flow.Chain, script string) *flow.TransactionBody {
+	txBody := flow.NewTransactionBody().
+		SetScript([]byte(script)).
+		SetPayer(chain.ServiceAddress()).
+		SetProposalKey(chain.ServiceAddress(), 0, 0).
+		AddAuthorizer(chain.ServiceAddress())
+	txBody.AddEnvelopeSignature(chain.ServiceAddress(), 0, []byte{1, 2, 3})
+	return txBody
+}
+
+func TestBlockTransactionsWithoutSystemChunkTransaction(t *testing.T) {
+	chain := flow.Mainnet.Chain()
+	systemTx, err := blueprints.SystemChunkTransaction(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userTxs := []*flow.TransactionBody{
+		userTransaction(chain, "transaction { execute { log(1) } }"),
+		userTransaction(chain, "transaction { execute { log(2) } }"),
+	}
+	// a system chunk transaction from another version, with a different script
+	otherSystemTx := flow.NewTransactionBody().
+		SetScript([]byte("transaction { execute { } }")).
+		AddAuthorizer(systemTx.Authorizers[0])
+
+	cases := []struct {
+		name     string
+		txBodies []*flow.TransactionBody
+	}{
+		{"no system transaction", userTxs},
+		{"system transaction", append(append([]*flow.TransactionBody{}, userTxs...), systemTx)},
+		{"system transaction of another version", append(append([]*flow.TransactionBody{}, userTxs...), otherSystemTx)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			accessBackend := &AccessBackend{
+				accessClient: &fakeAccessClient{header: &flow.Header{Height: 10, ChainID: chain.ChainID()}, txBodies: c.txBodies},
+				chain:        chain,
+				log:          zerolog.Nop(),
+			}
+			dpsBackend := &DPSBackend{
+				client: &fakeDPSClient{codec: zbor.NewCodec(), txBodies: c.txBodies},
+				codec:  zbor.NewCodec(),
+				chain:  chain,
+				log:    zerolog.Nop(),
+			}
+
+			for name, backend := range map[string]Backend{"access": accessBackend, "dps": dpsBackend} {
+				txBodies, err := backend.GetBlockTransactions(context.Background(), 10)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if len(txBodies) != len(userTxs) {
+					t.Fatalf("%s: expected %d transactions, got %d", name, len(userTxs), len(txBodies))
+				}
+				for i, txBody := range txBodies {
+					if txBody.ID() != userTxs[i].ID() {
+						t.Fatalf("%s: transaction %d is %s, expected %s", name, i, txBody.ID(), userTxs[i].ID())
+					}
+				}
+			}
+		})
+	}
+}
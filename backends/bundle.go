@@ -0,0 +1,85 @@
+package backends
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/model/flow"
+	"os"
+	"path/filepath"
+)
+
+// BundleRegister is a register value in a Bundle.
+// The owner and key are in the readable form and the value is hex encoded.
+type BundleRegister struct {
+	Owner string `json:"owner"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// BlockHeader is a flow.Header that is encoded to JSON field by field,
+// without the custom (un)marshalling of flow.Header.
+type BlockHeader flow.Header
+
+// Bundle contains everything needed to replay a transaction without network access.
+type Bundle struct {
+	ChainID       flow.ChainID          `json:"chainID"`
+	TransactionID flow.Identifier       `json:"transactionID"`
+	BlockHeight   uint64                `json:"blockHeight"`
+	BlockHeader   *BlockHeader          `json:"blockHeader"`
+	Transaction   *flow.TransactionBody `json:"transaction"`
+	Registers     []BundleRegister      `json:"registers"`
+	// Contracts are the contracts captured during the replay, by address and contract name.
+	Contracts map[string]map[string]string `json:"contracts"`
+}
+
+// SetRegisters replaces the bundle registers with the given register values.
+func (b *Bundle) SetRegisters(values map[registers.RegisterKey]flow.RegisterValue) {
+	b.Registers = make([]BundleRegister, 0, len(values))
+	for key, value := range values {
+		readable := key.ToReadable()
+		b.Registers = append(b.Registers, BundleRegister{
+			Owner: readable.Owner,
+			Key:   readable.Key,
+			Value: hex.EncodeToString(value),
+		})
+	}
+}
+
+// RegisterValues returns the bundle registers by key.
+func (b *Bundle) RegisterValues() (map[registers.RegisterKey]flow.RegisterValue, error) {
+	values := make(map[registers.RegisterKey]flow.RegisterValue, len(b.Registers))
+	for _, register := range b.Registers {
+		value, err := hex.DecodeString(register.Value)
+		if err != nil {
+			return nil, err
+		}
+		values[registers.RegisterKey{Owner: register.Owner, Key: register.Key}.ToMangled()] = value
+	}
+	return values, nil
+}
+
+func WriteBundle(filename string, bundle *Bundle) error {
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func ReadBundle(filename string) (*Bundle, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var bundle Bundle
+	err = json.Unmarshal(data, &bundle)
+	if err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
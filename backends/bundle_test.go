@@ -0,0 +1,132 @@
+package backends
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/model/flow"
+)
+
+func TestBundleRegistersRoundTrip(t *testing.T) {
+	address := flow.HexToAddress("1654653399040a61")
+	values := map[registers.RegisterKey]flow.RegisterValue{
+		// global registers have no owner
+		{Owner: "", Key: "uuid"}:                             {1, 2, 3},
+		{Owner: "", Key: "account_address_state"}:            {4},
+		{Owner: string(address.Bytes()), Key: "a.s"}:         {5, 6},
+		{Owner: string(address.Bytes()), Key: "$\x00\x01"}:   {7},
+		{Owner: string(flow.EmptyAddress.Bytes()), Key: "k"}: {8},
+	}
+
+	bundle := &Bundle{}
+	bundle.SetRegisters(values)
+	decoded, err := bundle.RegisterValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != len(values) {
+		t.Fatalf("expected %d registers, got %d", len(values), len(decoded))
+	}
+	for key, value := range values {
+		decodedValue, ok := decoded[key]
+		if !ok {
+			t.Fatalf("register %q/%q missing after round trip", key.Owner, key.Key)
+		}
+		if !bytes.Equal(value, decodedValue) {
+			t.Fatalf("register %q/%q: expected %x, got %x", key.Owner, key.Key, value, decodedValue)
+		}
+	}
+}
+
+func TestBundleWriteReadRoundTrip(t *testing.T) {
+	address := flow.HexToAddress("1654653399040a61")
+	header := &BlockHeader{
+		ChainID:            flow.Mainnet,
+		ParentID:           flow.Identifier{1},
+		Height:             40_000_000,
+		PayloadHash:        flow.Identifier{2},
+		Timestamp:          time.Date(2022, 12, 23, 17, 55, 50, 123, time.UTC),
+		View:               40_100_000,
+		ParentVoterIndices: []byte{3, 4},
+		ParentVoterSigData: []byte{5, 6},
+		ProposerID:         flow.Identifier{7},
+		ProposerSigData:    []byte{8, 9},
+	}
+	txBody := &flow.TransactionBody{
+		ReferenceBlockID: flow.Identifier{10},
+		Script:           []byte("transaction(a: Int) { prepare(signer: AuthAccount) { log(a) } }"),
+		Arguments:        [][]byte{[]byte(`{"type":"Int","value":"1"}`)},
+		GasLimit:         9999,
+		ProposalKey: flow.ProposalKey{
+			Address:        address,
+			KeyIndex:       1,
+			SequenceNumber: 42,
+		},
+		Payer:       address,
+		Authorizers: []flow.Address{address},
+		PayloadSignatures: []flow.TransactionSignature{
+			{Address: address, SignerIndex: 0, KeyIndex: 1, Signature: []byte{11}},
+		},
+		EnvelopeSignatures: []flow.TransactionSignature{
+			{Address: address, SignerIndex: 0, KeyIndex: 2, Signature: []byte{12}},
+		},
+	}
+	bundle := &Bundle{
+		ChainID:       flow.Mainnet,
+		TransactionID: txBody.ID(),
+		BlockHeight:   header.Height,
+		BlockHeader:   header,
+		Transaction:   txBody,
+		Contracts: map[string]map[string]string{
+			address.Hex(): {
+				"Test": "pub contract Test {}",
+			},
+		},
+	}
+	bundle.SetRegisters(map[registers.RegisterKey]flow.RegisterValue{
+		{Owner: string(address.Bytes()), Key: "a.s"}: {1},
+	})
+
+	filename := filepath.Join(t.TempDir(), "bundle", "bundle.json")
+	err := WriteBundle(filename, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBundle(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read.ChainID != bundle.ChainID {
+		t.Fatalf("expected chain ID %s, got %s", bundle.ChainID, read.ChainID)
+	}
+	if read.TransactionID != bundle.TransactionID {
+		t.Fatalf("expected transaction ID %s, got %s", bundle.TransactionID, read.TransactionID)
+	}
+	if read.BlockHeight != bundle.BlockHeight {
+		t.Fatalf("expected block height %d, got %d", bundle.BlockHeight, read.BlockHeight)
+	}
+	if !reflect.DeepEqual(read.BlockHeader, header) {
+		t.Fatalf("expected block header %+v, got %+v", header, read.BlockHeader)
+	}
+	if (*flow.Header)(read.BlockHeader).ID() != (*flow.Header)(header).ID() {
+		t.Fatal("block header ID changed in the round trip")
+	}
+	if !reflect.DeepEqual(read.Transaction, txBody) {
+		t.Fatalf("expected transaction %+v, got %+v", txBody, read.Transaction)
+	}
+	if read.Transaction.ID() != bundle.TransactionID {
+		t.Fatal("transaction ID changed in the round trip")
+	}
+	if !reflect.DeepEqual(read.Contracts, bundle.Contracts) {
+		t.Fatalf("expected contracts %v, got %v", bundle.Contracts, read.Contracts)
+	}
+	if !reflect.DeepEqual(read.Registers, bundle.Registers) {
+		t.Fatalf("expected registers %v, got %v", bundle.Registers, read.Registers)
+	}
+}
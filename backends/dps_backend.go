@@ -0,0 +1,195 @@
+package backends
+
+import (
+	"context"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-dps/api/dps"
+	"github.com/onflow/flow-dps/codec/zbor"
+	"github.com/onflow/flow-go/engine/execution/state"
+	"github.com/onflow/flow-go/ledger/common/pathfinder"
+	"github.com/onflow/flow-go/ledger/complete"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sort"
+)
+
+// DPSBackend reads chain data from a DPS archive node.
+type DPSBackend struct {
+	client dps.APIClient
+	conn   *grpc.ClientConn
+	codec  *zbor.Codec
+	chain  flow.Chain
+
+	log zerolog.Logger
+}
+
+var _ Backend = &DPSBackend{}
+
+func NewDPSBackend(archiveHost string, chain flow.Chain, log zerolog.Logger) (*DPSBackend, error) {
+	conn, err := grpc.Dial(
+		archiveHost,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("host", archiveHost).
+			Msg("Could not connect to server.")
+		return nil, err
+	}
+
+	return &DPSBackend{
+		client: dps.NewAPIClient(conn),
+		conn:   conn,
+		codec:  zbor.NewCodec(),
+		chain:  chain,
+		log:    log,
+	}, nil
+}
+
+func (b *DPSBackend) GetTransaction(ctx context.Context, txID flow.Identifier) (*flow.TransactionBody, error) {
+	txResult, err := b.client.GetTransaction(ctx, &dps.GetTransactionRequest{
+		TransactionID: txID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction.")
+		return nil, err
+	}
+	var txBody flow.TransactionBody
+	err = b.codec.Unmarshal(txResult.Data, &txBody)
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not unmarshal transaction.")
+		return nil, err
+	}
+	return &txBody, nil
+}
+
+func (b *DPSBackend) GetBlockHeader(ctx context.Context, blockHeight uint64) (*flow.Header, error) {
+	resp, err := b.client.GetHeader(ctx, &dps.GetHeaderRequest{
+		Height: blockHeight,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight).
+			Msg("Could not get block header.")
+		return nil, err
+	}
+	var header flow.Header
+	err = b.codec.Unmarshal(resp.Data, &header)
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not unmarshal block header.")
+		return nil, err
+	}
+	return &header, nil
+}
+
+func (b *DPSBackend) GetBlockTransactions(ctx context.Context, blockHeight uint64) ([]*flow.TransactionBody, error) {
+	resp, err := b.client.ListTransactionsForHeight(ctx, &dps.ListTransactionsForHeightRequest{
+		Height: blockHeight,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight).
+			Msg("Could not list block transactions.")
+		return nil, err
+	}
+
+	txBodies := make([]*flow.TransactionBody, 0, len(resp.TransactionIDs))
+	for _, txID := range resp.TransactionIDs {
+		txBody, err := b.GetTransaction(ctx, flow.HashToID(txID))
+		if err != nil {
+			return nil, err
+		}
+		txBodies = append(txBodies, txBody)
+	}
+	return withoutSystemChunkTransaction(txBodies, b.chain), nil
+}
+
+func (b *DPSBackend) GetTransactionBlockHeight(ctx context.Context, txID flow.Identifier) (uint64, error) {
+	resp, err := b.client.GetHeightForTransaction(ctx, &dps.GetHeightForTransactionRequest{
+		TransactionID: txID[:],
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not get transaction block height.")
+		return 0, err
+	}
+	return resp.GetHeight(), nil
+}
+
+// GetTransactionEvents filters the events of the transaction from the events of its block.
+func (b *DPSBackend) GetTransactionEvents(ctx context.Context, txID flow.Identifier) ([]flow.Event, error) {
+	blockHeight, err := b.GetTransactionBlockHeight(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.GetEvents(ctx, &dps.GetEventsRequest{
+		Height: blockHeight,
+	})
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Uint64("height", blockHeight).
+			Msg("Could not get block events.")
+		return nil, err
+	}
+	var blockEvents []flow.Event
+	err = b.codec.Unmarshal(resp.Data, &blockEvents)
+	if err != nil {
+		b.log.Error().
+			Err(err).
+			Msg("Could not unmarshal block events.")
+		return nil, err
+	}
+
+	events := make([]flow.Event, 0)
+	for _, event := range blockEvents {
+		if event.TransactionID == txID {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EventIndex < events[j].EventIndex
+	})
+	return events, nil
+}
+
+func (b *DPSBackend) GetRegisterValues(
+	ctx context.Context,
+	blockHeight uint64,
+	keys []registers.RegisterKey,
+) ([]flow.RegisterValue, error) {
+	paths := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		ledgerKey := state.RegisterIDToKey(flow.RegisterID{Key: key.Key, Owner: key.Owner})
+		ledgerPath, err := pathfinder.KeyToPath(ledgerKey, complete.DefaultPathFinderVersion)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, ledgerPath[:])
+	}
+
+	resp, err := b.client.GetRegisterValues(ctx, &dps.GetRegisterValuesRequest{
+		Height: blockHeight,
+		Paths:  paths,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
+}
+
+func (b *DPSBackend) Close() error {
+	return b.conn.Close()
+}
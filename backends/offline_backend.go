@@ -0,0 +1,79 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/model/flow"
+)
+
+// OfflineBackend serves the chain data from a Bundle, without network access.
+type OfflineBackend struct {
+	bundle    *Bundle
+	registers map[registers.RegisterKey]flow.RegisterValue
+}
+
+var _ Backend = &OfflineBackend{}
+
+func NewOfflineBackend(bundle *Bundle) (*OfflineBackend, error) {
+	values, err := bundle.RegisterValues()
+	if err != nil {
+		return nil, err
+	}
+	return &OfflineBackend{
+		bundle:    bundle,
+		registers: values,
+	}, nil
+}
+
+func (b *OfflineBackend) GetTransaction(_ context.Context, txID flow.Identifier) (*flow.TransactionBody, error) {
+	if txID != b.bundle.TransactionID {
+		return nil, fmt.Errorf("transaction %s is not in the offline bundle", txID)
+	}
+	return b.bundle.Transaction, nil
+}
+
+func (b *OfflineBackend) GetBlockHeader(_ context.Context, blockHeight uint64) (*flow.Header, error) {
+	if blockHeight != b.bundle.BlockHeight || b.bundle.BlockHeader == nil {
+		return nil, fmt.Errorf("block header at height %d is not in the offline bundle", blockHeight)
+	}
+	return (*flow.Header)(b.bundle.BlockHeader), nil
+}
+
+func (b *OfflineBackend) GetBlockTransactions(_ context.Context, blockHeight uint64) ([]*flow.TransactionBody, error) {
+	return nil, fmt.Errorf("transactions of block at height %d are not in the offline bundle", blockHeight)
+}
+
+func (b *OfflineBackend) GetTransactionBlockHeight(_ context.Context, txID flow.Identifier) (uint64, error) {
+	if txID != b.bundle.TransactionID {
+		return 0, fmt.Errorf("transaction %s is not in the offline bundle", txID)
+	}
+	return b.bundle.BlockHeight, nil
+}
+
+func (b *OfflineBackend) GetTransactionEvents(_ context.Context, txID flow.Identifier) ([]flow.Event, error) {
+	return nil, fmt.Errorf("events of transaction %s are not in the offline bundle", txID)
+}
+
+func (b *OfflineBackend) GetRegisterValues(
+	_ context.Context,
+	blockHeight uint64,
+	keys []registers.RegisterKey,
+) ([]flow.RegisterValue, error) {
+	if blockHeight != b.bundle.BlockHeight {
+		return nil, fmt.Errorf("registers at height %d are not in the offline bundle", blockHeight)
+	}
+	values := make([]flow.RegisterValue, 0, len(keys))
+	for _, key := range keys {
+		value, ok := b.registers[key]
+		if !ok {
+			return nil, fmt.Errorf("register %s is not in the offline bundle", key)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (b *OfflineBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/janezpodhostnik/flow-transaction-info/backends"
+	"github.com/onflow/flow-go/fvm"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// BlockDebugger replays all transactions of a block in order,
+// so that every transaction sees the changes of the transactions before it.
+// The system chunk transaction is not replayed, the backends do not return it with the block transactions.
+type BlockDebugger struct {
+	blockHeight uint64
+
+	// transactions is the template for the debuggers of the block transactions
+	transactions *TransactionDebugger
+
+	directory string
+
+	log zerolog.Logger
+}
+
+func NewBlockDebugger(
+	blockHeight uint64,
+	backend backends.Backend,
+	chain flow.Chain,
+	logger zerolog.Logger,
+	options ...TransactionDebuggerOption) *BlockDebugger {
+
+	directory := "b_" + strconv.FormatUint(blockHeight, 10)
+	transactions := NewTransactionDebugger(flow.ZeroID, backend, chain, logger, options...)
+	transactions.directory = directory
+
+	return &BlockDebugger{
+		blockHeight:  blockHeight,
+		transactions: transactions,
+		directory:    directory,
+		log:          logger,
+	}
+}
+
+func (d *BlockDebugger) RunBlock(ctx context.Context) error {
+	d.log.Info().
+		Uint64("height", d.blockHeight).
+		Msg("Running block. This may differ from how the block was actually run on the network.")
+
+	header, err := d.transactions.backend.GetBlockHeader(ctx, d.blockHeight)
+	if err != nil {
+		return err
+	}
+	err = d.transactions.dumpBlockHeaderToFile(header)
+	if err != nil {
+		return err
+	}
+
+	txBodies, err := d.transactions.backend.GetBlockTransactions(ctx, d.blockHeight)
+	if err != nil {
+		return err
+	}
+	d.log.Info().
+		Int("transactions", len(txBodies)).
+		Msg("Got block transactions. The system chunk transaction at the end of the block is not replayed.")
+
+	source := d.transactions.registerSource(ctx, d.blockHeight)
+	defer func() {
+		err := source.Close()
+		if err != nil {
+			d.log.Warn().
+				Err(err).
+				Msg("Could not close register source.")
+		}
+	}()
+
+	cache, err := d.transactions.registerCache(d.blockHeight)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := cache.Close()
+		if err != nil {
+			d.log.Warn().
+				Err(err).
+				Msg("Could not close register cache.")
+		}
+	}()
+	err = d.transactions.prefetch(cache, source)
+	if err != nil {
+		return err
+	}
+
+	// reads the value a register had before the block, without tracking the read
+	previousValueFunc := cache.Wrap(source.Get)
+
+	// the block view accumulates the changes of all the block transactions
+	blockView := NewRemoteView(previousValueFunc)
+
+	summary := NewBlockSummary(d.directory, d.log)
+	for i, txBody := range txBodies {
+		txDebugger := d.transactionDebugger(i, txBody.ID())
+
+		replay, err := txDebugger.replayTransaction(ctx, header, txBody, uint32(i), blockView.Get)
+		if err != nil {
+			return err
+		}
+		err = blockView.MergeView(replay.view)
+		if err != nil {
+			return err
+		}
+
+		summary.Add(replay.tx)
+	}
+
+	err = summary.Close()
+	if err != nil {
+		return err
+	}
+	if d.transactions.verify {
+		// the network state is only known at the end of the block,
+		// so the writes of all the block transactions are verified together
+		executedSource := d.transactions.registerSource(ctx, d.blockHeight+1)
+		err = d.transactions.verifyRegisterWrites(blockView, previousValueFunc, executedSource.GetMany)
+		if closeErr := executedSource.Close(); closeErr != nil {
+			d.log.Warn().
+				Err(closeErr).
+				Msg("Could not close register source.")
+		}
+	}
+	return err
+}
+
+// transactionDebugger returns a debugger for the i-th transaction of the block
+// that writes its output to a subdirectory of the block directory.
+func (d *BlockDebugger) transactionDebugger(i int, txID flow.Identifier) *TransactionDebugger {
+	txDebugger := *d.transactions
+	txDebugger.txID = txID
+	txDebugger.directory = filepath.Join(d.directory, fmt.Sprintf("%03d_%s", i, txID))
+	return &txDebugger
+}
+
+type blockSummaryEntry struct {
+	txID            flow.Identifier
+	computationUsed uint64
+	memoryEstimate  uint64
+	events          int
+	err             string
+}
+
+// BlockSummary collects the computation, events and errors of the block transactions.
+type BlockSummary struct {
+	entries  []blockSummaryEntry
+	filename string
+
+	log zerolog.Logger
+}
+
+func NewBlockSummary(directory string, log zerolog.Logger) *BlockSummary {
+	return &BlockSummary{
+		filename: directory + "/block_summary.csv",
+		entries:  []blockSummaryEntry{},
+		log:      log,
+	}
+}
+
+func (s *BlockSummary) Add(tx *fvm.TransactionProcedure) {
+	entry := blockSummaryEntry{
+		txID:            tx.ID,
+		computationUsed: tx.ComputationUsed,
+		memoryEstimate:  tx.MemoryEstimate,
+		events:          len(tx.Events),
+	}
+	if tx.Err != nil {
+		entry.err = tx.Err.Error()
+	}
+	s.entries = append(s.entries, entry)
+}
+
+func (s *BlockSummary) Close() error {
+	var computationUsed uint64
+	var events, failed int
+	for _, entry := range s.entries {
+		computationUsed += entry.computationUsed
+		events += entry.events
+		if entry.err != "" {
+			failed++
+		}
+	}
+	s.log.Info().
+		Int("transactions", len(s.entries)).
+		Int("failed", failed).
+		Uint64("computationUsed", computationUsed).
+		Int("events", events).
+		Msg("Block summary.")
+
+	err := os.MkdirAll(filepath.Dir(s.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	csvFile, err := os.Create(s.filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := csvFile.Close()
+		if err != nil {
+			s.log.Error().Err(err).Msg("error closing csv file")
+		}
+	}()
+
+	csvwriter := csv.NewWriter(csvFile)
+	defer csvwriter.Flush()
+	err = csvwriter.Write([]string{"Index", "Transaction ID", "Computation", "Memory estimate", "Events", "Error"})
+	if err != nil {
+		return err
+	}
+	for i, entry := range s.entries {
+		err := csvwriter.Write([]string{
+			strconv.Itoa(i),
+			entry.txID.String(),
+			strconv.FormatUint(entry.computationUsed, 10),
+			strconv.FormatUint(entry.memoryEstimate, 10),
+			strconv.Itoa(entry.events),
+			entry.err,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
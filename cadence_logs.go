@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type cadenceLog struct {
+	// caller is the function and position of the statement that emitted the log
+	caller  string
+	message string
+}
+
+// CadenceLogs collects the messages the transaction logged with the Cadence log function,
+// with the location they were logged from, and optionally prints them as they are logged.
+type CadenceLogs struct {
+	logs []cadenceLog
+	live bool
+
+	log      zerolog.Logger
+	filename string
+}
+
+func NewCadenceLogs(directory string, live bool, log zerolog.Logger) *CadenceLogs {
+	return &CadenceLogs{
+		logs:     []cadenceLog{},
+		live:     live,
+		log:      log,
+		filename: directory + "/cadence_logs.txt",
+	}
+}
+
+// OnLog records a program log, it is a ProfileBuilder log listener.
+func (l *CadenceLogs) OnLog(caller string, message string) {
+	l.logs = append(l.logs, cadenceLog{
+		caller:  caller,
+		message: message,
+	})
+	if l.live {
+		l.log.Info().
+			Str("caller", caller).
+			Str("message", message).
+			Msg("Cadence log.")
+	}
+}
+
+func (l *CadenceLogs) Close() error {
+	l.log.Info().
+		Int("logs", len(l.logs)).
+		Msg("Cadence logs.")
+
+	err := os.MkdirAll(filepath.Dir(l.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	var builder strings.Builder
+	for _, log := range l.logs {
+		_, _ = fmt.Fprintf(&builder, "%s: %s\n", log.caller, log.message)
+	}
+	return os.WriteFile(l.filename, []byte(builder.String()), 0644)
+}
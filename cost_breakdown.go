@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// logCostBreakdownTop is the number of kinds with the largest contribution that are logged
+const logCostBreakdownTop = 5
+
+type costBreakdownEntry struct {
+	kind         string
+	intensity    uint64
+	weight       uint64
+	contribution float64
+	percent      float64
+}
+
+// CostBreakdown is how much each kind of intensity contributed to the weighted total,
+// sorted by contribution.
+type CostBreakdown struct {
+	entries []costBreakdownEntry
+	total   float64
+}
+
+// NewCostBreakdown multiplies the intensities with their weights.
+// The weighted intensities are divided by precision, as the fvm meter does for computation.
+func NewCostBreakdown(
+	intensities map[uint64]uint64,
+	weights map[uint64]uint64,
+	precision uint64,
+	kindNameMap map[uint64]string,
+) *CostBreakdown {
+	b := &CostBreakdown{
+		entries: make([]costBreakdownEntry, 0, len(intensities)),
+	}
+	for kind, intensity := range intensities {
+		name, ok := kindNameMap[kind]
+		if !ok {
+			name = strconv.Itoa(int(kind))
+		}
+		weight := weights[kind]
+		contribution := float64(intensity) * float64(weight) / float64(precision)
+		b.total += contribution
+		b.entries = append(b.entries, costBreakdownEntry{
+			kind:         name,
+			intensity:    intensity,
+			weight:       weight,
+			contribution: contribution,
+		})
+	}
+	for i := range b.entries {
+		if b.total > 0 {
+			b.entries[i].percent = 100 * b.entries[i].contribution / b.total
+		}
+	}
+	sort.Slice(b.entries, func(i, j int) bool {
+		if b.entries[i].contribution != b.entries[j].contribution {
+			return b.entries[i].contribution > b.entries[j].contribution
+		}
+		return b.entries[i].kind < b.entries[j].kind
+	})
+	return b
+}
+
+// Log logs the kinds with the largest contribution.
+func (b *CostBreakdown) Log(log zerolog.Logger, msg string) {
+	logEvent := log.Info().
+		Float64("total", b.total)
+	for i, entry := range b.entries {
+		if i >= logCostBreakdownTop || entry.contribution == 0 {
+			break
+		}
+		logEvent = logEvent.Float64(entry.kind, entry.percent)
+	}
+	logEvent.Msg(msg)
+}
+
+func (b *CostBreakdown) Write(filename string, log zerolog.Logger) error {
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	csvFile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func(csvFile *os.File) {
+		err := csvFile.Close()
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not close csv file.")
+		}
+	}(csvFile)
+
+	writer := csv.NewWriter(csvFile)
+	defer writer.Flush()
+	err = writer.Write([]string{"Kind", "Intensity", "Weight", "Contribution", "Percent"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range b.entries {
+		err := writer.Write([]string{
+			entry.kind,
+			strconv.FormatUint(entry.intensity, 10),
+			strconv.FormatUint(entry.weight, 10),
+			strconv.FormatFloat(entry.contribution, 'f', 2, 64),
+			strconv.FormatFloat(entry.percent, 'f', 2, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
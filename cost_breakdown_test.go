@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCostBreakdown(t *testing.T) {
+	kindNames := map[uint64]string{
+		1: "Statement",
+		2: "Loop",
+		3: "FunctionInvocation",
+	}
+
+	tests := []struct {
+		name          string
+		intensities   map[uint64]uint64
+		weights       map[uint64]uint64
+		precision     uint64
+		expected      []costBreakdownEntry
+		expectedTotal float64
+	}{
+		{
+			name:          "no intensities",
+			intensities:   map[uint64]uint64{},
+			weights:       map[uint64]uint64{1: 1},
+			precision:     1,
+			expected:      []costBreakdownEntry{},
+			expectedTotal: 0,
+		},
+		{
+			name:        "sorted by contribution",
+			intensities: map[uint64]uint64{1: 10, 2: 5, 3: 2},
+			weights:     map[uint64]uint64{1: 1 << 16, 2: 1 << 16, 3: 10 << 16},
+			precision:   1 << 16,
+			expected: []costBreakdownEntry{
+				{kind: "FunctionInvocation", intensity: 2, weight: 10 << 16, contribution: 20, percent: 57.142857142857146},
+				{kind: "Statement", intensity: 10, weight: 1 << 16, contribution: 10, percent: 28.571428571428573},
+				{kind: "Loop", intensity: 5, weight: 1 << 16, contribution: 5, percent: 14.285714285714286},
+			},
+			expectedTotal: 35,
+		},
+		{
+			name:        "unknown kinds and kinds without weight",
+			intensities: map[uint64]uint64{1: 3, 2: 7, 42: 1},
+			weights:     map[uint64]uint64{42: 2},
+			precision:   4,
+			expected: []costBreakdownEntry{
+				{kind: "42", intensity: 1, weight: 2, contribution: 0.5, percent: 100},
+				{kind: "Loop", intensity: 7, weight: 0, contribution: 0, percent: 0},
+				{kind: "Statement", intensity: 3, weight: 0, contribution: 0, percent: 0},
+			},
+			expectedTotal: 0.5,
+		},
+		{
+			name:        "no weights",
+			intensities: map[uint64]uint64{1: 3},
+			weights:     map[uint64]uint64{},
+			precision:   1,
+			expected: []costBreakdownEntry{
+				{kind: "Statement", intensity: 3, weight: 0, contribution: 0, percent: 0},
+			},
+			expectedTotal: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breakdown := NewCostBreakdown(test.intensities, test.weights, test.precision, kindNames)
+
+			if breakdown.total != test.expectedTotal {
+				t.Fatalf("expected total %v, got %v", test.expectedTotal, breakdown.total)
+			}
+			if len(breakdown.entries) != len(test.expected) {
+				t.Fatalf("expected %d entries, got %d", len(test.expected), len(breakdown.entries))
+			}
+			for i, entry := range breakdown.entries {
+				if entry != test.expected[i] {
+					t.Fatalf("entry %d: expected %+v, got %+v", i, test.expected[i], entry)
+				}
+			}
+		})
+	}
+}
+
+func TestCostBreakdownWrite(t *testing.T) {
+	breakdown := NewCostBreakdown(
+		map[uint64]uint64{1: 3, 2: 1},
+		map[uint64]uint64{1: 1, 2: 1},
+		1,
+		map[uint64]string{1: "Statement", 2: "Loop"},
+	)
+
+	filename := filepath.Join(t.TempDir(), "cost_breakdown.csv")
+	err := breakdown.Write(filename, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Kind,Intensity,Weight,Contribution,Percent\n" +
+		"Statement,3,1,3.00,75.00\n" +
+		"Loop,1,1,1.00,25.00\n"
+	if string(written) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, written)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/google/pprof/profile"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffReportTop is the number of functions with the largest change in effort that are reported
+const diffReportTop = 20
+
+// OutputDiff compares the output directories of two runs, base and other,
+// e.g. two transactions or the same transaction before and after a contract upgrade.
+type OutputDiff struct {
+	baseDirectory  string
+	otherDirectory string
+	directory      string
+
+	log zerolog.Logger
+}
+
+func NewOutputDiff(baseDirectory string, otherDirectory string, directory string, log zerolog.Logger) *OutputDiff {
+	return &OutputDiff{
+		baseDirectory:  baseDirectory,
+		otherDirectory: otherDirectory,
+		directory:      directory,
+		log:            log,
+	}
+}
+
+// Run writes the difference of the profiles as diff.pb.gz and a report as diff.txt.
+func (d *OutputDiff) Run() error {
+	err := os.MkdirAll(d.directory, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	report := &strings.Builder{}
+	_, _ = fmt.Fprintf(report, "base:  %s\nother: %s\n", d.baseDirectory, d.otherDirectory)
+
+	err = d.diffProfiles(report)
+	if err != nil {
+		return err
+	}
+	err = d.diffComputationIntensities(report)
+	if err != nil {
+		return err
+	}
+	err = d.diffRegistersRead(report)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(d.directory, "diff.txt"), []byte(report.String()), 0644)
+	if err != nil {
+		return err
+	}
+	d.log.Info().
+		Str("directory", d.directory).
+		Msg("Wrote diff.")
+	_, err = io.WriteString(os.Stdout, report.String())
+	return err
+}
+
+// diffProfiles writes a profile of the other profile minus the base profile,
+// with the base samples labeled the same way as go tool pprof -diff_base does.
+func (d *OutputDiff) diffProfiles(report io.Writer) error {
+	base, err := readProfile(filepath.Join(d.baseDirectory, "profile.pb.gz"))
+	if err != nil {
+		return err
+	}
+	other, err := readProfile(filepath.Join(d.otherDirectory, "profile.pb.gz"))
+	if err != nil {
+		return err
+	}
+
+	baseEffort := functionEffort(base)
+	otherEffort := functionEffort(other)
+
+	base.Scale(-1)
+	for _, sample := range base.Sample {
+		if sample.Label == nil {
+			sample.Label = make(map[string][]string)
+		}
+		sample.Label["pprof::base"] = []string{"true"}
+	}
+	diff, err := profile.Merge([]*profile.Profile{base, other})
+	if err != nil {
+		return fmt.Errorf("could not merge profiles: %w", err)
+	}
+	err = writeProfile(filepath.Join(d.directory, "diff.pb.gz"), diff)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(otherEffort))
+	for name := range baseEffort {
+		names = append(names, name)
+	}
+	for name := range otherEffort {
+		if _, ok := baseEffort[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		deltaI := abs(otherEffort[names[i]] - baseEffort[names[i]])
+		deltaJ := abs(otherEffort[names[j]] - baseEffort[names[j]])
+		if deltaI != deltaJ {
+			return deltaI > deltaJ
+		}
+		return names[i] < names[j]
+	})
+
+	_, _ = fmt.Fprintf(report, "\nFunctions with the largest change in execution effort (flat):\n")
+	_, _ = fmt.Fprintf(report, "%10s %10s %10s  %s\n", "base", "other", "delta", "function")
+	for i, name := range names {
+		if i >= diffReportTop {
+			break
+		}
+		delta := otherEffort[name] - baseEffort[name]
+		if delta == 0 {
+			break
+		}
+		_, _ = fmt.Fprintf(report, "%10d %10d %+10d  %s\n", baseEffort[name], otherEffort[name], delta, name)
+	}
+	return nil
+}
+
+func (d *OutputDiff) diffComputationIntensities(report io.Writer) error {
+	base, err := readIntensities(filepath.Join(d.baseDirectory, "computation_intensities.csv"))
+	if err != nil {
+		return err
+	}
+	other, err := readIntensities(filepath.Join(d.otherDirectory, "computation_intensities.csv"))
+	if err != nil {
+		return err
+	}
+
+	kinds := make([]string, 0, len(other))
+	for kind := range base {
+		kinds = append(kinds, kind)
+	}
+	for kind := range other {
+		if _, ok := base[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+
+	_, _ = fmt.Fprintf(report, "\nComputation intensities that changed:\n")
+	_, _ = fmt.Fprintf(report, "%10s %10s %10s  %s\n", "base", "other", "delta", "kind")
+	for _, kind := range kinds {
+		delta := other[kind] - base[kind]
+		if delta == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(report, "%10d %10d %+10d  %s\n", base[kind], other[kind], delta, kind)
+	}
+	return nil
+}
+
+func (d *OutputDiff) diffRegistersRead(report io.Writer) error {
+	base, err := readRegistersRead(filepath.Join(d.baseDirectory, "registers_read.csv"))
+	if err != nil {
+		return err
+	}
+	other, err := readRegistersRead(filepath.Join(d.otherDirectory, "registers_read.csv"))
+	if err != nil {
+		return err
+	}
+
+	var baseBytes, otherBytes int64
+	for _, read := range base {
+		baseBytes += read
+	}
+	for _, read := range other {
+		otherBytes += read
+	}
+
+	_, _ = fmt.Fprintf(report, "\nRegisters read:\n")
+	_, _ = fmt.Fprintf(report, "%10s %10s %10s\n", "base", "other", "delta")
+	_, _ = fmt.Fprintf(report, "%10d %10d %+10d  registers\n", len(base), len(other), len(other)-len(base))
+	_, _ = fmt.Fprintf(report, "%10d %10d %+10d  bytes\n", baseBytes, otherBytes, otherBytes-baseBytes)
+
+	writeOnlyIn := func(title string, reads map[registers.RegisterKey]int64, others map[registers.RegisterKey]int64) {
+		keys := make([]registers.RegisterKey, 0)
+		for key := range reads {
+			if _, ok := others[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Owner != keys[j].Owner {
+				return keys[i].Owner < keys[j].Owner
+			}
+			return keys[i].Key < keys[j].Key
+		})
+		_, _ = fmt.Fprintf(report, "\nRegisters only read by %s:\n", title)
+		for _, key := range keys {
+			_, _ = fmt.Fprintf(report, "%10d  %s %s\n", reads[key], key.Owner, key.Key)
+		}
+	}
+	writeOnlyIn("base", base, other)
+	writeOnlyIn("other", other, base)
+	return nil
+}
+
+// functionEffort returns the flat execution effort per function.
+func functionEffort(p *profile.Profile) map[string]int64 {
+	effort := make(map[string]int64)
+	for _, sample := range p.Sample {
+		if len(sample.Location) == 0 || len(sample.Location[0].Line) == 0 {
+			continue
+		}
+		fn := locationFunction(sample.Location[0])
+		effort[fn.Name+" "+fn.Filename] += sample.Value[0]
+	}
+	return effort
+}
+
+func readProfile(filename string) (*profile.Profile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return profile.Parse(f)
+}
+
+func writeProfile(filename string, p *profile.Profile) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = p.Write(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readIntensities reads an intensities csv into intensities by kind name.
+func readIntensities(filename string) (map[string]int64, error) {
+	records, err := readCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+	intensities := make(map[string]int64, len(records))
+	for _, record := range records[1:] {
+		if len(record) < 2 {
+			continue
+		}
+		intensity, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse intensity in %s: %w", filename, err)
+		}
+		intensities[record[0]] += intensity
+	}
+	return intensities, nil
+}
+
+// readRegistersRead reads a register read trace into the bytes read by register.
+func readRegistersRead(filename string) (map[registers.RegisterKey]int64, error) {
+	records, err := readCSV(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerColumn, keyColumn, bytesColumn := -1, -1, -1
+	for i, column := range records[0] {
+		switch column {
+		case "Owner":
+			ownerColumn = i
+		case "Key":
+			keyColumn = i
+		case "bytes":
+			bytesColumn = i
+		}
+	}
+	if ownerColumn < 0 || keyColumn < 0 || bytesColumn < 0 {
+		return nil, fmt.Errorf("register read trace %s has no Owner, Key or bytes column", filename)
+	}
+
+	reads := make(map[registers.RegisterKey]int64, len(records))
+	for _, record := range records[1:] {
+		read, err := strconv.ParseInt(record[bytesColumn], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse bytes read in %s: %w", filename, err)
+		}
+		key := registers.RegisterKey{Owner: record[ownerColumn], Key: record[keyColumn]}
+		if read > reads[key] {
+			reads[key] = read
+		}
+	}
+	return reads, nil
+}
+
+func readCSV(filename string) ([][]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", filename)
+	}
+	return records, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type reportedEvent struct {
+	Index uint32 `json:"index"`
+	Type  string `json:"type"`
+	// Payload is the JSON-CDC encoded event
+	Payload json.RawMessage `json:"payload"`
+	// Value is the event in Cadence syntax
+	Value string `json:"value"`
+}
+
+type eventsComparison struct {
+	Match       bool             `json:"match"`
+	Differences []string         `json:"differences"`
+	Network     []*reportedEvent `json:"network"`
+}
+
+// EventsReport reports the events the transaction emitted,
+// and optionally compares them to the events the network recorded for the transaction.
+type EventsReport struct {
+	Events     []*reportedEvent  `json:"events"`
+	Comparison *eventsComparison `json:"comparison,omitempty"`
+
+	log      zerolog.Logger
+	filename string
+}
+
+func NewEventsReport(events []flow.Event, directory string, log zerolog.Logger) (*EventsReport, error) {
+	reported, err := reportEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	return &EventsReport{
+		Events:   reported,
+		log:      log,
+		filename: directory + "/events.json",
+	}, nil
+}
+
+func reportEvents(events []flow.Event) ([]*reportedEvent, error) {
+	reported := make([]*reportedEvent, 0, len(events))
+	for _, event := range events {
+		value, err := jsoncdc.Decode(nil, event.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode event %d of type %s: %w", event.EventIndex, event.Type, err)
+		}
+		reported = append(reported, &reportedEvent{
+			Index:   event.EventIndex,
+			Type:    string(event.Type),
+			Payload: event.Payload,
+			Value:   value.String(),
+		})
+	}
+	return reported, nil
+}
+
+// feeDeductionEventSuffixes are the suffixes of the types of the events emitted by the fee deduction,
+// which runs after the transaction, in reverse emission order.
+var feeDeductionEventSuffixes = []string{
+	".FlowFees.FeesDeducted",
+	".FlowFees.TokensDeposited",
+	".FlowToken.TokensDeposited",
+	".FlowToken.TokensWithdrawn",
+}
+
+// WithoutFeeDeductionEvents returns the events without the events of the fee deduction at the end,
+// for comparing network events to the events of a replay that does not deduct fees.
+func WithoutFeeDeductionEvents(events []flow.Event) []flow.Event {
+	if len(events) == 0 || !strings.HasSuffix(string(events[len(events)-1].Type), feeDeductionEventSuffixes[0]) {
+		return events
+	}
+	end := len(events) - 1
+	for _, suffix := range feeDeductionEventSuffixes[1:] {
+		if end > 0 && strings.HasSuffix(string(events[end-1].Type), suffix) {
+			end--
+		}
+	}
+	return events[:end]
+}
+
+// Compare compares the emitted events to the events the network recorded, in emission order.
+// Events are compared by their type and decoded value, so differences in the payload encoding are ignored.
+func (r *EventsReport) Compare(networkEvents []flow.Event) error {
+	network, err := reportEvents(networkEvents)
+	if err != nil {
+		return err
+	}
+	comparison := &eventsComparison{
+		Differences: []string{},
+		Network:     network,
+	}
+	for i := 0; i < len(r.Events) || i < len(network); i++ {
+		switch {
+		case i >= len(network):
+			comparison.Differences = append(comparison.Differences,
+				fmt.Sprintf("event %d: %s was not emitted on the network", i, r.Events[i].Type))
+		case i >= len(r.Events):
+			comparison.Differences = append(comparison.Differences,
+				fmt.Sprintf("event %d: %s was not emitted in the replay", i, network[i].Type))
+		case r.Events[i].Type != network[i].Type:
+			comparison.Differences = append(comparison.Differences,
+				fmt.Sprintf("event %d: type %s, network type %s", i, r.Events[i].Type, network[i].Type))
+		case r.Events[i].Value != network[i].Value:
+			comparison.Differences = append(comparison.Differences,
+				fmt.Sprintf("event %d: %s, network %s", i, r.Events[i].Value, network[i].Value))
+		}
+	}
+	comparison.Match = len(comparison.Differences) == 0
+	r.Comparison = comparison
+	return nil
+}
+
+func (r *EventsReport) Close() error {
+	level := zerolog.InfoLevel
+	if r.Comparison != nil && !r.Comparison.Match {
+		level = zerolog.WarnLevel
+	}
+	logEvent := r.log.WithLevel(level).
+		Int("events", len(r.Events))
+	if r.Comparison != nil {
+		logEvent = logEvent.
+			Int("networkEvents", len(r.Comparison.Network)).
+			Int("differences", len(r.Comparison.Differences))
+	}
+	logEvent.Msg("Transaction events.")
+
+	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.filename, data, 0644)
+}
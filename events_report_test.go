@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/onflow/flow-go/model/flow"
+)
+
+func TestWithoutFeeDeductionEvents(t *testing.T) {
+	const (
+		withdrawn     = "A.1654653399040a61.FlowToken.TokensWithdrawn"
+		deposited     = "A.1654653399040a61.FlowToken.TokensDeposited"
+		feesDeposited = "A.f919ee77447b7497.FlowFees.TokensDeposited"
+		feesDeducted  = "A.f919ee77447b7497.FlowFees.FeesDeducted"
+		nftDeposit    = "A.1d7e57aa55817448.NonFungibleToken.Deposit"
+	)
+
+	tests := []struct {
+		name     string
+		events   []flow.EventType
+		expected []flow.EventType
+	}{
+		{
+			name:     "no events",
+			events:   []flow.EventType{},
+			expected: []flow.EventType{},
+		},
+		{
+			name:     "only fee deduction",
+			events:   []flow.EventType{withdrawn, deposited, feesDeposited, feesDeducted},
+			expected: []flow.EventType{},
+		},
+		{
+			name:     "transaction events and fee deduction",
+			events:   []flow.EventType{nftDeposit, withdrawn, deposited, feesDeposited, feesDeducted},
+			expected: []flow.EventType{nftDeposit},
+		},
+		{
+			name: "FlowToken transfer and fee deduction",
+			events: []flow.EventType{
+				withdrawn, deposited,
+				withdrawn, deposited, feesDeposited, feesDeducted,
+			},
+			expected: []flow.EventType{withdrawn, deposited},
+		},
+		{
+			name: "FlowToken transfer and fee deduction without the FlowToken deposit",
+			events: []flow.EventType{
+				withdrawn, deposited,
+				withdrawn, feesDeposited, feesDeducted,
+			},
+			expected: []flow.EventType{withdrawn, deposited},
+		},
+		{
+			name:     "FlowToken transfer without fee deduction",
+			events:   []flow.EventType{withdrawn, deposited},
+			expected: []flow.EventType{withdrawn, deposited},
+		},
+		{
+			name:     "fee deduction not at the end",
+			events:   []flow.EventType{withdrawn, deposited, feesDeposited, feesDeducted, nftDeposit},
+			expected: []flow.EventType{withdrawn, deposited, feesDeposited, feesDeducted, nftDeposit},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := make([]flow.Event, 0, len(test.events))
+			for i, eventType := range test.events {
+				events = append(events, flow.Event{Type: eventType, EventIndex: uint32(i)})
+			}
+
+			filtered := WithoutFeeDeductionEvents(events)
+
+			types := make([]flow.EventType, 0, len(filtered))
+			for i, event := range filtered {
+				if event.EventIndex != uint32(i) {
+					t.Fatalf("expected the events in emission order, event %d has index %d", i, event.EventIndex)
+				}
+				types = append(types, event.Type)
+			}
+			if fmt.Sprint(types) != fmt.Sprint(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, types)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go/fvm"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type pipelineStepComputation struct {
+	Step            string `json:"step"`
+	ComputationUsed uint64 `json:"computationUsed"`
+}
+
+// FeeReport reports the fees charged for the transaction and the computation used by each processing step.
+type FeeReport struct {
+	Payer              string                    `json:"payer"`
+	FeesCharged        string                    `json:"feesCharged"`
+	InclusionEffort    string                    `json:"inclusionEffort"`
+	ExecutionEffort    string                    `json:"executionEffort"`
+	PayerBalanceBefore string                    `json:"payerBalanceBefore"`
+	PayerBalanceAfter  string                    `json:"payerBalanceAfter"`
+	Steps              []pipelineStepComputation `json:"steps"`
+
+	log      zerolog.Logger
+	filename string
+}
+
+func NewFeeReport(
+	payer flow.Address,
+	balanceBefore cadence.UFix64,
+	balanceAfter cadence.UFix64,
+	events []flow.Event,
+	steps []*PipelineStep,
+	directory string,
+	log zerolog.Logger,
+) (*FeeReport, error) {
+	r := &FeeReport{
+		Payer:              payer.HexWithPrefix(),
+		PayerBalanceBefore: balanceBefore.String(),
+		PayerBalanceAfter:  balanceAfter.String(),
+		log:                log,
+		filename:           directory + "/fees.json",
+	}
+
+	var executionEffort uint64
+	for _, event := range events {
+		if !strings.HasSuffix(string(event.Type), ".FlowFees.FeesDeducted") {
+			continue
+		}
+		value, err := jsoncdc.Decode(nil, event.Payload)
+		if err != nil {
+			return nil, err
+		}
+		feesDeducted, ok := value.(cadence.Event)
+		if !ok {
+			continue
+		}
+		for i, field := range feesDeducted.EventType.Fields {
+			switch field.Identifier {
+			case "amount":
+				r.FeesCharged = feesDeducted.Fields[i].String()
+			case "inclusionEffort":
+				r.InclusionEffort = feesDeducted.Fields[i].String()
+			case "executionEffort":
+				r.ExecutionEffort = feesDeducted.Fields[i].String()
+				if effort, ok := feesDeducted.Fields[i].(cadence.UFix64); ok {
+					executionEffort = uint64(effort)
+				}
+			}
+		}
+	}
+
+	for _, step := range steps {
+		computation := step.ComputationUsed
+		// fees are deducted during the transaction invocation,
+		// the execution effort the fees were charged for is the computation used before the fee deduction
+		if _, ok := step.processor.(fvm.TransactionInvoker); ok && executionEffort > 0 && computation >= executionEffort {
+			r.Steps = append(r.Steps, pipelineStepComputation{
+				Step:            step.Name,
+				ComputationUsed: executionEffort,
+			})
+			r.Steps = append(r.Steps, pipelineStepComputation{
+				Step:            "fee deduction",
+				ComputationUsed: computation - executionEffort,
+			})
+			continue
+		}
+		r.Steps = append(r.Steps, pipelineStepComputation{
+			Step:            step.Name,
+			ComputationUsed: computation,
+		})
+	}
+
+	return r, nil
+}
+
+func (r *FeeReport) Close() error {
+	r.log.Info().
+		Str("payer", r.Payer).
+		Str("feesCharged", r.FeesCharged).
+		Str("payerBalanceBefore", r.PayerBalanceBefore).
+		Str("payerBalanceAfter", r.PayerBalanceAfter).
+		Msg("Transaction fees.")
+
+	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.filename, data, 0644)
+}
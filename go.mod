@@ -3,10 +3,14 @@ module github.com/janezpodhostnik/flow-transaction-info
 go 1.19
 
 require (
+	github.com/fxamacker/cbor/v2 v2.4.1-0.20220515183430-ad2eae63303f
 	github.com/google/pprof v0.0.0-20220818150347-1763105d910c
+	github.com/onflow/atree v0.4.0
 	github.com/onflow/cadence v0.28.1-0.20221223171403-ac91356b44aa
 	github.com/onflow/flow-dps v1.3.4-0.20220831153436-e9e0f57d6ce1
 	github.com/onflow/flow-go v0.28.17-0.20221223175550-80a861fffa6d
+	github.com/onflow/flow-go/crypto v0.24.4
+	github.com/onflow/flow/protobuf/go/flow v0.3.1
 	github.com/rs/zerolog v1.28.0
 	google.golang.org/grpc v1.47.0
 )
@@ -27,7 +31,6 @@ require (
 	github.com/ef-ds/deque v1.0.4 // indirect
 	github.com/ethereum/go-ethereum v1.9.13 // indirect
 	github.com/fsnotify/fsnotify v1.5.4 // indirect
-	github.com/fxamacker/cbor/v2 v2.4.1-0.20220515183430-ad2eae63303f // indirect
 	github.com/fxamacker/circlehash v0.3.0 // indirect
 	github.com/gammazero/deque v0.1.0 // indirect
 	github.com/go-kit/kit v0.10.0 // indirect
@@ -74,13 +77,10 @@ require (
 	github.com/multiformats/go-multicodec v0.5.0 // indirect
 	github.com/multiformats/go-multihash v0.2.1 // indirect
 	github.com/multiformats/go-varint v0.0.6 // indirect
-	github.com/onflow/atree v0.4.0 // indirect
 	github.com/onflow/flow-core-contracts/lib/go/contracts v0.11.2-0.20220720151516-797b149ceaaa // indirect
 	github.com/onflow/flow-core-contracts/lib/go/templates v0.11.2-0.20220720151516-797b149ceaaa // indirect
 	github.com/onflow/flow-ft/lib/go/contracts v0.5.0 // indirect
 	github.com/onflow/flow-go-sdk v0.29.0 // indirect
-	github.com/onflow/flow-go/crypto v0.24.4 // indirect
-	github.com/onflow/flow/protobuf/go/flow v0.3.1 // indirect
 	github.com/onflow/sdks v0.4.4 // indirect
 	github.com/pbnjay/memory v0.0.0-20210728143218-7b4eea64cf58 // indirect
 	github.com/pelletier/go-toml v1.9.5 // indirect
@@ -3,35 +3,193 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"github.com/janezpodhostnik/flow-transaction-info/backends"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
 	"github.com/onflow/flow-go/model/flow"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
+	"strings"
 )
 
 func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+
 	var host string
 	flag.StringVar(&host, "host", "", "host url with port")
 
+	var backendName string
+	flag.StringVar(&backendName, "backend", "dps", "backend to use: dps or access")
+
+	var executionHost string
+	flag.StringVar(&executionHost, "execution-host", "", "execution node host url with port, used to read registers with the access backend (defaults to host)")
+
 	var tx string
 	flag.StringVar(&tx, "tx", "", "transaction id")
 
+	var blockHeight uint64
+	flag.Uint64Var(&blockHeight, "block", 0, "block height to replay all the transactions of, in order, instead of a single transaction")
+
+	var account string
+	flag.StringVar(&account, "account", "", "address of an account to print the storage of as it was before -block, instead of replaying")
+
+	var chainName string
+	flag.StringVar(&chainName, "chain", "mainnet", "chain to use: mainnet, testnet, sandboxnet, emulator or localnet")
+
+	var verify bool
+	flag.BoolVar(&verify, "verify", false, "verify the replayed register writes against the network execution")
+
+	var compareEvents bool
+	flag.BoolVar(&compareEvents, "compare-events", false, "compare the emitted events to the events the network recorded for the transaction")
+
+	var printLogs bool
+	flag.BoolVar(&printLogs, "print-logs", false, "print the Cadence log output of the transaction as it is logged")
+
+	var batchSize int
+	flag.IntVar(&batchSize, "batch-size", registers.DefaultBatchSize, "maximum number of registers fetched in one request")
+
+	var prefetch string
+	flag.StringVar(&prefetch, "prefetch", "", "comma separated register traces (e.g. t_<id>/registers_read.csv) to prefetch")
+
+	var fullPipeline bool
+	flag.BoolVar(&fullPipeline, "full-pipeline", false, "run the sequence number check, signature verification and fee deduction as well")
+
+	var profileFormat string
+	flag.StringVar(&profileFormat, "profile-format", string(ProfileFormatPprof), "comma separated execution profile formats: pprof, folded, speedscope or trace, pprof is always written")
+
+	var export string
+	flag.StringVar(&export, "export", "", "file to export an offline replay bundle to")
+
+	var offline string
+	flag.StringVar(&offline, "offline", "", "offline replay bundle to replay the transaction from, without network access")
+
 	flag.Parse()
 
-	txid, err := flow.HexStringToIdentifier(tx)
+	var bundle *backends.Bundle
+	if offline != "" {
+		var err error
+		bundle, err = backends.ReadBundle(offline)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Could not read offline bundle.")
+			return
+		}
+		if tx == "" {
+			tx = bundle.TransactionID.String()
+		}
+		chainName = string(bundle.ChainID)
+	}
+
+	profileFormats, err := ParseProfileFormats(profileFormat)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Msg("Could not parse transaction ID.")
+			Msg("Could not parse profile format.")
+		return
+	}
+
+	chain, err := chainFromName(chainName)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Could not parse chain.")
 		return
 	}
 
-	chain := flow.Mainnet.Chain()
 	ctx := context.Background()
 
-	txErr, err := NewTransactionDebugger(txid, host, chain, log.Logger).RunTransaction(ctx)
+	var backend backends.Backend
+	if bundle != nil {
+		backend, err = backends.NewOfflineBackend(bundle)
+	} else {
+		backend, err = newBackend(backendName, host, executionHost, chain)
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Could not create backend.")
+		return
+	}
+	defer func() {
+		err := backend.Close()
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not close backend.")
+		}
+	}()
+
+	options := []TransactionDebuggerOption{
+		WithBatchSize(batchSize),
+		WithProfileFormats(profileFormats...),
+	}
+	if verify {
+		options = append(options, WithVerification())
+	}
+	if fullPipeline {
+		options = append(options, WithFullPipeline())
+	}
+	if compareEvents {
+		options = append(options, WithEventComparison())
+	}
+	if printLogs {
+		options = append(options, WithLiveLogs())
+	}
+	if export != "" {
+		options = append(options, WithExport(export))
+	}
+	if prefetch != "" {
+		options = append(options, WithPrefetch(strings.Split(prefetch, ",")...))
+	}
+
+	if account != "" {
+		address := flow.HexToAddress(account)
+		if !chain.IsValid(address) {
+			log.Error().
+				Str("account", account).
+				Msg("Not an account address of the chain.")
+			return
+		}
+		if blockHeight == 0 {
+			log.Error().
+				Msg("The block height is required to print the account storage.")
+			return
+		}
+		err := NewStorageExplorer(address, blockHeight, backend, chain, log.Logger, options...).Run(ctx)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Could not print account storage.")
+		}
+		return
+	}
+
+	if blockHeight != 0 {
+		err := NewBlockDebugger(blockHeight, backend, chain, log.Logger, options...).RunBlock(ctx)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Implementation error.")
+		}
+		return
+	}
+
+	txid, err := flow.HexStringToIdentifier(tx)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Could not parse transaction ID.")
+		return
+	}
+
+	txErr, err := NewTransactionDebugger(txid, backend, chain, log.Logger, options...).RunTransaction(ctx)
 
 	if txErr != nil {
 		log.Error().
@@ -46,3 +204,59 @@ func main() {
 		return
 	}
 }
+
+// runDiff compares the output directories of two runs.
+func runDiff(arguments []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: %s diff [flags] <base output directory> <other output directory>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	var output string
+	flags.StringVar(&output, "output", "diff", "directory to write the diff to")
+
+	_ = flags.Parse(arguments)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return
+	}
+
+	err := NewOutputDiff(flags.Arg(0), flags.Arg(1), output, log.Logger).Run()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Could not diff outputs.")
+	}
+}
+
+func newBackend(name string, host string, executionHost string, chain flow.Chain) (backends.Backend, error) {
+	switch strings.ToLower(name) {
+	case "dps":
+		return backends.NewDPSBackend(host, chain, log.Logger)
+	case "access":
+		if executionHost == "" {
+			executionHost = host
+		}
+		return backends.NewAccessBackend(host, executionHost, chain, log.Logger)
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", name)
+	}
+}
+
+func chainFromName(name string) (flow.Chain, error) {
+	switch strings.ToLower(name) {
+	case "mainnet", string(flow.Mainnet):
+		return flow.Mainnet.Chain(), nil
+	case "testnet", string(flow.Testnet):
+		return flow.Testnet.Chain(), nil
+	case "sandboxnet", string(flow.Sandboxnet):
+		return flow.Sandboxnet.Chain(), nil
+	case "emulator", string(flow.Emulator):
+		return flow.Emulator.Chain(), nil
+	case "localnet", string(flow.Localnet):
+		return flow.Localnet.Chain(), nil
+	default:
+		return nil, fmt.Errorf("unknown chain: %s", name)
+	}
+}
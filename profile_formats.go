@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/google/pprof/profile"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ProfileFormat string
+
+const (
+	// ProfileFormatPprof is a gzipped pprof protobuf, for go tool pprof
+	ProfileFormatPprof ProfileFormat = "pprof"
+	// ProfileFormatFolded is Brendan Gregg's folded stacks text, for flamegraph.pl
+	ProfileFormatFolded ProfileFormat = "folded"
+	// ProfileFormatSpeedscope is a speedscope JSON file, for https://www.speedscope.app
+	ProfileFormatSpeedscope ProfileFormat = "speedscope"
+	// ProfileFormatTrace is a Chrome trace of the execution timeline, for https://ui.perfetto.dev
+	ProfileFormatTrace ProfileFormat = "trace"
+)
+
+// DefaultProfileFormats are the profile formats written if none are chosen.
+var DefaultProfileFormats = []ProfileFormat{ProfileFormatPprof}
+
+// ParseProfileFormats parses a comma separated list of profile formats.
+// The pprof format is always included, as the diff of two runs and the register reads profile are based on it.
+func ParseProfileFormats(formats string) ([]ProfileFormat, error) {
+	parsed := []ProfileFormat{ProfileFormatPprof}
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		switch ProfileFormat(format) {
+		case ProfileFormatPprof:
+		case ProfileFormatFolded, ProfileFormatSpeedscope, ProfileFormatTrace:
+			parsed = append(parsed, ProfileFormat(format))
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown profile format: %s", format)
+		}
+	}
+	return parsed, nil
+}
+
+func (p *ProfileBuilder) writePprof() (err error) {
+	filename := p.directory + "/profile.pb.gz"
+	err = os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	// Write the profile to the file.
+	return p.Profile.Write(f)
+}
+
+// writeRegisterReadsPprof writes the register reads by the call stack that caused them.
+func (p *ProfileBuilder) writeRegisterReadsPprof() (err error) {
+	filename := p.directory + "/registers_read.pb.gz"
+
+	// the functions and locations are shared with the execution profile
+	reads := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{
+				Type: "register reads",
+				Unit: "count",
+			},
+			{
+				Type: "register bytes read",
+				Unit: "bytes",
+			},
+		},
+		DefaultSampleType: "register bytes read",
+		Sample:            p.readSamples,
+		Location:          p.Profile.Location,
+		Function:          p.Profile.Function,
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	return reads.Write(f)
+}
+
+// writeFolded writes one line per distinct stack, from the root to the leaf function,
+// followed by the execution effort spent in that stack.
+func (p *ProfileBuilder) writeFolded() (err error) {
+	filename := p.directory + "/profile.folded"
+	err = os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	stacks := make(map[string]int64)
+	for _, sample := range p.Profile.Sample {
+		names := make([]string, 0, len(sample.Location))
+		// sample locations are leaf first
+		for i := len(sample.Location) - 1; i >= 0; i-- {
+			names = append(names, locationFunction(sample.Location[i]).Name)
+		}
+		stacks[strings.Join(names, ";")] += sample.Value[0]
+	}
+	folded := make([]string, 0, len(stacks))
+	for stack := range stacks {
+		folded = append(folded, stack)
+	}
+	sort.Strings(folded)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	writer := bufio.NewWriter(f)
+	for _, stack := range folded {
+		_, err := writer.WriteString(stack + " " + strconv.FormatInt(stacks[stack], 10) + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// https://github.com/jlfwong/speedscope/blob/main/src/lib/file-format-spec.ts
+type speedscopeFile struct {
+	Schema             string                     `json:"$schema"`
+	Shared             speedscopeShared           `json:"shared"`
+	Profiles           []speedscopeSampledProfile `json:"profiles"`
+	Name               string                     `json:"name"`
+	ActiveProfileIndex int                        `json:"activeProfileIndex"`
+	Exporter           string                     `json:"exporter"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+}
+
+type speedscopeSampledProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+// writeSpeedscope writes the samples in execution order as a sampled speedscope profile,
+// with a frame per function.
+func (p *ProfileBuilder) writeSpeedscope() error {
+	filename := p.directory + "/profile.speedscope.json"
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	frames := make([]speedscopeFrame, 0, len(p.Profile.Function))
+	frameIndex := make(map[uint64]int, len(p.Profile.Function))
+	for _, fn := range p.Profile.Function {
+		frameIndex[fn.ID] = len(frames)
+		frames = append(frames, speedscopeFrame{
+			Name: fn.Name,
+			File: fn.Filename,
+		})
+	}
+
+	sampled := speedscopeSampledProfile{
+		Type:    "sampled",
+		Name:    filepath.Base(p.directory),
+		Unit:    "none",
+		Samples: make([][]int, 0, len(p.Profile.Sample)),
+		Weights: make([]int64, 0, len(p.Profile.Sample)),
+	}
+	for _, sample := range p.Profile.Sample {
+		stack := make([]int, 0, len(sample.Location))
+		// sample locations are leaf first, speedscope stacks are root first
+		for i := len(sample.Location) - 1; i >= 0; i-- {
+			stack = append(stack, frameIndex[locationFunction(sample.Location[i]).ID])
+		}
+		sampled.Samples = append(sampled.Samples, stack)
+		sampled.Weights = append(sampled.Weights, sample.Value[0])
+		sampled.EndValue += sample.Value[0]
+	}
+
+	data, err := json.Marshal(speedscopeFile{
+		Schema:   "https://www.speedscope.app/file-format-schema.json",
+		Shared:   speedscopeShared{Frames: frames},
+		Profiles: []speedscopeSampledProfile{sampled},
+		Name:     filepath.Base(p.directory),
+		Exporter: "flow-transaction-info",
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func locationFunction(location *profile.Location) *profile.Function {
+	return location.Line[0].Function
+}
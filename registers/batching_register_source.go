@@ -0,0 +1,264 @@
+package registers
+
+import (
+	"fmt"
+	"github.com/onflow/atree"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"sync"
+)
+
+// DefaultBatchSize is the default maximum number of registers requested in one round-trip.
+const DefaultBatchSize = 100
+
+// readAheadLimit is the maximum number of registers that were read ahead and not read yet that are kept.
+const readAheadLimit = 1000
+
+// DefaultParallelism is the default maximum number of requests in flight when reading many registers.
+const DefaultParallelism = 8
+
+// RegisterBatchGetFunc reads the values of many registers in one round-trip.
+// The returned values are in the same order as the requested keys.
+type RegisterBatchGetFunc func([]RegisterKey) ([]flow.RegisterValue, error)
+
+type pendingRead struct {
+	done  chan struct{}
+	value flow.RegisterValue
+	err   error
+	// readAhead is true if the register was only requested because its parent slab was read
+	readAhead bool
+}
+
+// BatchingRegisterSource coalesces register reads into multi-register requests.
+// While requests are in flight, all reads requested in the meantime are queued
+// and sent together in the next request, up to batchSize registers per request
+// and up to parallelism requests at the same time.
+//
+// The fvm reads registers one at a time, so Get also reads ahead: when a read register is an atree slab,
+// the slabs it references are requested together in the background, as they are likely to be read next.
+type BatchingRegisterSource struct {
+	batchGet    RegisterBatchGetFunc
+	batchSize   int
+	parallelism int
+
+	mu       sync.Mutex
+	pending  map[RegisterKey]*pendingRead
+	queue    []RegisterKey
+	inFlight int
+	// readAhead are the registers that were read ahead and not read yet, readAheadOrder in the order they were read
+	readAhead      map[RegisterKey]*pendingRead
+	readAheadOrder []RegisterKey
+	// fetched are the registers that were already fetched, they are not read ahead again
+	fetched map[RegisterKey]struct{}
+
+	reads         int
+	roundTrips    int
+	readAheads    int
+	readAheadHits int
+
+	log zerolog.Logger
+}
+
+func NewBatchingRegisterSource(
+	batchGet RegisterBatchGetFunc,
+	batchSize int,
+	parallelism int,
+	log zerolog.Logger,
+) *BatchingRegisterSource {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &BatchingRegisterSource{
+		batchGet:    batchGet,
+		batchSize:   batchSize,
+		parallelism: parallelism,
+		pending:     make(map[RegisterKey]*pendingRead),
+		readAhead:   make(map[RegisterKey]*pendingRead),
+		fetched:     make(map[RegisterKey]struct{}),
+		log:         log,
+	}
+}
+
+// Get reads a single register. It has the signature of a RegisterGetRegisterFunc.
+// Registers that were read ahead are returned without a round-trip.
+func (s *BatchingRegisterSource) Get(owner string, key string) (flow.RegisterValue, error) {
+	registerKey := RegisterKey{owner, key}
+	p := s.enqueue(registerKey)
+	s.flush()
+	<-p.done
+	if p.err == nil && registerKey.IsSlab() {
+		s.readChildSlabsAhead(registerKey, p.value)
+	}
+	return p.value, p.err
+}
+
+// readChildSlabsAhead requests the slabs the slab references in the background.
+func (s *BatchingRegisterSource) readChildSlabsAhead(key RegisterKey, value flow.RegisterValue) {
+	if len(value) == 0 {
+		return
+	}
+	slab, err := DecodeSlab(key, value)
+	if err != nil {
+		// not every register with a slab key is decodable, reading ahead is best effort
+		return
+	}
+
+	s.mu.Lock()
+	queued := false
+	for _, storable := range slab.ChildStorables() {
+		child, ok := storable.(atree.StorageIDStorable)
+		if !ok {
+			continue
+		}
+		childKey := SlabRegisterKey(atree.StorageID(child))
+		if _, ok := s.pending[childKey]; ok {
+			continue
+		}
+		if _, ok := s.fetched[childKey]; ok {
+			continue
+		}
+		s.pending[childKey] = &pendingRead{
+			done:      make(chan struct{}),
+			readAhead: true,
+		}
+		s.queue = append(s.queue, childKey)
+		s.readAheads++
+		queued = true
+	}
+	s.mu.Unlock()
+
+	if queued {
+		go s.flush()
+	}
+}
+
+// GetMany reads many registers, sending them in as few round-trips as the batch size allows.
+func (s *BatchingRegisterSource) GetMany(keys []RegisterKey) ([]flow.RegisterValue, error) {
+	reads := make([]*pendingRead, 0, len(keys))
+	for _, key := range keys {
+		reads = append(reads, s.enqueue(key))
+	}
+	for i := 0; i < s.parallelism; i++ {
+		go s.flush()
+	}
+
+	values := make([]flow.RegisterValue, 0, len(keys))
+	for _, p := range reads {
+		<-p.done
+		if p.err != nil {
+			return nil, p.err
+		}
+		values = append(values, p.value)
+	}
+	return values, nil
+}
+
+func (s *BatchingRegisterSource) enqueue(key RegisterKey) *pendingRead {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reads++
+	if p, ok := s.readAhead[key]; ok {
+		delete(s.readAhead, key)
+		s.readAheadHits++
+		return p
+	}
+	p, ok := s.pending[key]
+	if ok && p.readAhead {
+		// the register is being read ahead
+		p.readAhead = false
+		s.readAheadHits++
+	}
+	if !ok {
+		p = &pendingRead{done: make(chan struct{})}
+		s.pending[key] = p
+		s.queue = append(s.queue, key)
+	}
+	return p
+}
+
+// flush sends the queued reads unless enough requests are already in flight.
+func (s *BatchingRegisterSource) flush() {
+	for {
+		s.mu.Lock()
+		if s.inFlight >= s.parallelism || len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		n := len(s.queue)
+		if n > s.batchSize {
+			n = s.batchSize
+		}
+		batch := s.queue[:n]
+		s.queue = s.queue[n:]
+		s.inFlight++
+		s.roundTrips++
+		s.mu.Unlock()
+
+		values, err := s.batchGet(batch)
+		if err == nil && len(values) != len(batch) {
+			err = fmt.Errorf("expected %d register values, got %d", len(batch), len(values))
+		}
+
+		s.mu.Lock()
+		for i, key := range batch {
+			p := s.pending[key]
+			delete(s.pending, key)
+			if err != nil {
+				p.err = err
+			} else {
+				p.value = values[i]
+			}
+			if err == nil {
+				s.fetched[key] = struct{}{}
+				if p.readAhead {
+					s.storeReadAhead(key, p)
+				}
+			}
+			close(p.done)
+		}
+		s.inFlight--
+		s.mu.Unlock()
+	}
+}
+
+// storeReadAhead keeps the register that was read ahead until it is read,
+// evicting the registers that were read ahead the longest ago if there are more than readAheadLimit.
+func (s *BatchingRegisterSource) storeReadAhead(key RegisterKey, p *pendingRead) {
+	s.readAhead[key] = p
+	s.readAheadOrder = append(s.readAheadOrder, key)
+	for len(s.readAhead) > readAheadLimit {
+		delete(s.readAhead, s.readAheadOrder[0])
+		s.readAheadOrder = s.readAheadOrder[1:]
+	}
+	if len(s.readAheadOrder) > 2*readAheadLimit {
+		// drop the registers that were read since
+		order := make([]RegisterKey, 0, len(s.readAhead))
+		for _, k := range s.readAheadOrder {
+			if _, ok := s.readAhead[k]; ok {
+				order = append(order, k)
+			}
+		}
+		s.readAheadOrder = order
+	}
+}
+
+// Close logs how many round-trips were saved by batching and reading ahead.
+// The round-trips include the requests of registers that were read ahead but never read.
+func (s *BatchingRegisterSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Info().
+		Int("reads", s.reads).
+		Int("roundTrips", s.roundTrips).
+		Int("roundTripsSaved", s.reads-s.roundTrips).
+		Int("readAheads", s.readAheads).
+		Int("readAheadHits", s.readAheadHits).
+		Int("batchSize", s.batchSize).
+		Msg("Register batching stats.")
+	return nil
+}
@@ -0,0 +1,229 @@
+package registers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/onflow/atree"
+	"github.com/onflow/cadence/runtime/interpreter"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+)
+
+// fakeRegisters serves register values and records the requested batches.
+type fakeRegisters struct {
+	mu      sync.Mutex
+	values  map[RegisterKey]flow.RegisterValue
+	batches [][]RegisterKey
+	err     error
+	// release, if set, is waited on before every batch is served
+	release chan struct{}
+}
+
+func (f *fakeRegisters) batchGet(keys []RegisterKey) ([]flow.RegisterValue, error) {
+	if f.release != nil {
+		<-f.release
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batches = append(f.batches, append([]RegisterKey{}, keys...))
+	if f.err != nil {
+		return nil, f.err
+	}
+	values := make([]flow.RegisterValue, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, f.values[key])
+	}
+	return values, nil
+}
+
+func (f *fakeRegisters) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sizes := make([]int, 0, len(f.batches))
+	for _, batch := range f.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestBatchingRegisterSourceBatchSizes(t *testing.T) {
+	fake := &fakeRegisters{values: map[RegisterKey]flow.RegisterValue{}}
+	keys := make([]RegisterKey, 0, 250)
+	for i := 0; i < 250; i++ {
+		key := RegisterKey{Owner: "owner", Key: fmt.Sprintf("key%d", i)}
+		fake.values[key] = flow.RegisterValue{byte(i)}
+		keys = append(keys, key)
+	}
+
+	source := NewBatchingRegisterSource(fake.batchGet, 100, 1, zerolog.Nop())
+	values, err := source.GetMany(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if !bytes.Equal(values[i], fake.values[key]) {
+			t.Fatalf("register %v: expected %x, got %x", key, fake.values[key], values[i])
+		}
+	}
+
+	sizes := fake.batchSizes()
+	expected := []int{100, 100, 50}
+	if fmt.Sprint(sizes) != fmt.Sprint(expected) {
+		t.Fatalf("expected batch sizes %v, got %v", expected, sizes)
+	}
+}
+
+// slabRegisters stores an array big enough to be split into many slabs
+// and returns the registers of the slabs and the key of the root slab.
+func slabRegisters(t *testing.T) (map[RegisterKey]flow.RegisterValue, RegisterKey) {
+	storage := interpreter.NewInMemoryStorage(nil)
+	array, err := atree.NewArray(
+		storage,
+		atree.Address{0, 0, 0, 0, 0, 0, 0, 1},
+		interpreter.VariableSizedStaticType{Type: interpreter.PrimitiveStaticTypeUInt64},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		err = array.Append(interpreter.NewUnmeteredUInt64Value(uint64(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	encoded, err := storage.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[RegisterKey]flow.RegisterValue, len(encoded))
+	for id, value := range encoded {
+		values[SlabRegisterKey(id)] = value
+	}
+	return values, SlabRegisterKey(array.StorageID())
+}
+
+func TestBatchingRegisterSourceReadAhead(t *testing.T) {
+	values, rootKey := slabRegisters(t)
+	if len(values) < 3 {
+		t.Fatalf("expected the array to be split into slabs, got %d registers", len(values))
+	}
+	fake := &fakeRegisters{values: values}
+	source := NewBatchingRegisterSource(fake.batchGet, 100, 1, zerolog.Nop())
+
+	value, err := source.Get(rootKey.Owner, rootKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(value, values[rootKey]) {
+		t.Fatal("unexpected root slab value")
+	}
+
+	// the children were read ahead in one round-trip, the root slab is read again last
+	keys := make([]RegisterKey, 0, len(values))
+	for key := range values {
+		if key != rootKey {
+			keys = append(keys, key)
+		}
+	}
+	keys = append(keys, rootKey)
+	for _, key := range keys {
+		value, err := source.Get(key.Owner, key.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, values[key]) {
+			t.Fatalf("register %v: unexpected value", key)
+		}
+	}
+	sizes := fake.batchSizes()
+	// the root slab, the children read ahead and the root slab read again
+	expected := []int{1, len(values) - 1, 1}
+	if fmt.Sprint(sizes) != fmt.Sprint(expected) {
+		t.Fatalf("expected batch sizes %v, got %v", expected, sizes)
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	// the children were already fetched, so reading the root slab again did not read them ahead again
+	if source.readAheads != len(values)-1 {
+		t.Fatalf("expected %d registers read ahead, got %d", len(values)-1, source.readAheads)
+	}
+	if source.readAheadHits != len(values)-1 {
+		t.Fatalf("expected %d read ahead hits, got %d", len(values)-1, source.readAheadHits)
+	}
+	if len(source.readAhead) != 0 {
+		t.Fatalf("expected the read ahead registers to be consumed, %d left", len(source.readAhead))
+	}
+}
+
+func TestBatchingRegisterSourceReadAheadLimit(t *testing.T) {
+	source := NewBatchingRegisterSource(nil, 1, 1, zerolog.Nop())
+	n := 3*readAheadLimit + 1
+	for i := 0; i < n; i++ {
+		key := RegisterKey{Owner: "owner", Key: fmt.Sprintf("key%d", i)}
+		source.storeReadAhead(key, &pendingRead{done: make(chan struct{})})
+	}
+
+	if len(source.readAhead) != readAheadLimit {
+		t.Fatalf("expected %d read ahead registers, got %d", readAheadLimit, len(source.readAhead))
+	}
+	if len(source.readAheadOrder) > 2*readAheadLimit {
+		t.Fatalf("expected the read ahead order to be bounded, got %d", len(source.readAheadOrder))
+	}
+	if _, ok := source.readAhead[RegisterKey{Owner: "owner", Key: "key0"}]; ok {
+		t.Fatal("expected the oldest read ahead register to be evicted")
+	}
+	if _, ok := source.readAhead[RegisterKey{Owner: "owner", Key: fmt.Sprintf("key%d", n-1)}]; !ok {
+		t.Fatal("expected the newest read ahead register to be kept")
+	}
+}
+
+func TestBatchingRegisterSourceErrors(t *testing.T) {
+	batchErr := errors.New("batch failed")
+	fake := &fakeRegisters{
+		err:     batchErr,
+		release: make(chan struct{}),
+	}
+	source := NewBatchingRegisterSource(fake.batchGet, 100, 1, zerolog.Nop())
+
+	const waiters = 10
+	errs := make(chan error, waiters+1)
+	for i := 0; i < waiters; i++ {
+		// some waiters read the same register
+		key := RegisterKey{Owner: "owner", Key: fmt.Sprintf("key%d", i%3)}
+		go func() {
+			_, err := source.Get(key.Owner, key.Key)
+			errs <- err
+		}()
+	}
+	go func() {
+		_, err := source.GetMany([]RegisterKey{{Owner: "owner", Key: "key0"}, {Owner: "owner", Key: "other"}})
+		errs <- err
+	}()
+
+	// wait until every read is waiting, then fail the batches
+	for {
+		source.mu.Lock()
+		reads := source.reads
+		source.mu.Unlock()
+		if reads == waiters+2 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(fake.release)
+
+	for i := 0; i < waiters+1; i++ {
+		if err := <-errs; !errors.Is(err, batchErr) {
+			t.Fatalf("expected %v, got %v", batchErr, err)
+		}
+	}
+}
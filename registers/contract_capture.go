@@ -47,6 +47,11 @@ func (c *CaptureContractWrapper) Wrap(inner RegisterGetRegisterFunc) RegisterGet
 	}
 }
 
+// Contracts returns the captured contract code by address and contract name.
+func (c *CaptureContractWrapper) Contracts() map[string]map[string]string {
+	return c.contracts
+}
+
 func (c *CaptureContractWrapper) Close() error {
 	for account, contracts := range c.contracts {
 		for name, code := range contracts {
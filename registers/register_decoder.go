@@ -0,0 +1,347 @@
+package registers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/onflow/atree"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/cadence/runtime/interpreter"
+	"github.com/onflow/flow-go/fvm/environment"
+	"github.com/onflow/flow-go/fvm/state"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type RegisterKind string
+
+const (
+	RegisterKindAccountStatus     RegisterKind = "account status"
+	RegisterKindStorageDomain     RegisterKind = "storage domain"
+	RegisterKindArrayDataSlab     RegisterKind = "atree array data slab"
+	RegisterKindArrayMetaDataSlab RegisterKind = "atree array meta data slab"
+	RegisterKindMapDataSlab       RegisterKind = "atree map data slab"
+	RegisterKindMapMetaDataSlab   RegisterKind = "atree map meta data slab"
+	RegisterKindStorableSlab      RegisterKind = "atree storable slab"
+	RegisterKindContractCode      RegisterKind = "contract code"
+	RegisterKindContractNames     RegisterKind = "contract names"
+	RegisterKindPublicKey         RegisterKind = "public key"
+	RegisterKindUnknown           RegisterKind = "unknown"
+)
+
+const publicKeyPrefix = "public_key_"
+
+// storageDomains are the keys of the registers holding the storage index of a Cadence storage domain.
+var storageDomains = map[string]struct{}{
+	"storage":  {},
+	"public":   {},
+	"private":  {},
+	"contract": {},
+}
+
+// DecodedRegister is a register value decoded into what it contains.
+type DecodedRegister struct {
+	Owner   string       `json:"owner"`
+	Key     string       `json:"key"`
+	Kind    RegisterKind `json:"kind"`
+	Size    int          `json:"size"`
+	Content interface{}  `json:"content,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type decodedAccountStatus struct {
+	Frozen         bool   `json:"frozen"`
+	StorageUsed    uint64 `json:"storageUsed"`
+	StorageIndex   string `json:"storageIndex"`
+	PublicKeyCount uint64 `json:"publicKeyCount"`
+}
+
+type decodedStorageDomain struct {
+	StorageIndex string `json:"storageIndex"`
+}
+
+type decodedSlab struct {
+	// Type is only set on the root slab of an array or map
+	Type string `json:"type,omitempty"`
+	// ChildStorables are the elements of a data slab, keys and values alternate for maps,
+	// or the child slabs of a meta data slab
+	ChildStorables []string `json:"childStorables"`
+}
+
+type decodedPublicKey struct {
+	Index     int    `json:"index"`
+	PublicKey string `json:"publicKey"`
+	SignAlgo  string `json:"signAlgo"`
+	HashAlgo  string `json:"hashAlgo"`
+	Weight    int    `json:"weight"`
+	SeqNumber uint64 `json:"seqNumber"`
+	Revoked   bool   `json:"revoked"`
+}
+
+// RegisterDecoder decodes the first value read of every register and writes them to registers_decoded.json.
+type RegisterDecoder struct {
+	decoded  []DecodedRegister
+	seen     map[RegisterKey]struct{}
+	filename string
+
+	log zerolog.Logger
+}
+
+var _ RegisterGetWrapper = &RegisterDecoder{}
+
+func NewRegisterDecoder(directory string, log zerolog.Logger) *RegisterDecoder {
+	return &RegisterDecoder{
+		decoded:  []DecodedRegister{},
+		seen:     make(map[RegisterKey]struct{}),
+		filename: directory + "/registers_decoded.json",
+		log:      log,
+	}
+}
+
+func (r *RegisterDecoder) Wrap(inner RegisterGetRegisterFunc) RegisterGetRegisterFunc {
+	return func(owner string, key string) (flow.RegisterValue, error) {
+		val, err := inner(owner, key)
+		if err != nil {
+			return nil, err
+		}
+
+		k := RegisterKey{owner, key}
+		if _, ok := r.seen[k]; !ok {
+			r.seen[k] = struct{}{}
+			r.decoded = append(r.decoded, DecodeRegister(k, val))
+		}
+
+		return val, nil
+	}
+}
+
+// DecodeRegister classifies the register by its key and decodes its value.
+// If the value cannot be decoded the error is set instead of the content.
+func DecodeRegister(key RegisterKey, value flow.RegisterValue) DecodedRegister {
+	readable := key.ToReadable()
+	decoded := DecodedRegister{
+		Owner: readable.Owner,
+		Key:   readable.Key,
+		Kind:  RegisterKindUnknown,
+		Size:  len(value),
+	}
+	if len(value) == 0 {
+		return decoded
+	}
+
+	var err error
+	_, isDomain := storageDomains[key.Key]
+	switch {
+	case key.IsSlab():
+		decoded.Kind, decoded.Content, err = decodeSlab(key, value)
+	case key.Key == state.KeyAccountStatus:
+		decoded.Kind = RegisterKindAccountStatus
+		decoded.Content, err = decodeAccountStatus(value)
+	case isDomain:
+		decoded.Kind = RegisterKindStorageDomain
+		decoded.Content = decodedStorageDomain{
+			StorageIndex: "$" + hex.EncodeToString(value),
+		}
+	case strings.HasPrefix(key.Key, state.KeyCode+"."):
+		decoded.Kind = RegisterKindContractCode
+		decoded.Content = string(value)
+	case key.Key == state.KeyContractNames:
+		decoded.Kind = RegisterKindContractNames
+		var names []string
+		err = interpreter.CBORDecMode.Unmarshal(value, &names)
+		decoded.Content = names
+	case strings.HasPrefix(key.Key, publicKeyPrefix):
+		decoded.Kind = RegisterKindPublicKey
+		decoded.Content, err = decodePublicKey(key, value)
+	}
+	if err != nil {
+		decoded.Content = nil
+		decoded.Error = err.Error()
+	}
+	return decoded
+}
+
+func decodeAccountStatus(value flow.RegisterValue) (interface{}, error) {
+	status, err := environment.AccountStatusFromBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	index := status.StorageIndex()
+	return decodedAccountStatus{
+		Frozen:         status.IsAccountFrozen(),
+		StorageUsed:    status.StorageUsed(),
+		StorageIndex:   "$" + hex.EncodeToString(index[:]),
+		PublicKeyCount: status.PublicKeyCount(),
+	}, nil
+}
+
+func decodePublicKey(key RegisterKey, value flow.RegisterValue) (interface{}, error) {
+	index, err := strconv.ParseUint(strings.TrimPrefix(key.Key, publicKeyPrefix), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := flow.DecodeAccountPublicKey(value, index)
+	if err != nil {
+		return nil, err
+	}
+	return decodedPublicKey{
+		Index:     publicKey.Index,
+		PublicKey: publicKey.PublicKey.String(),
+		SignAlgo:  publicKey.SignAlgo.String(),
+		HashAlgo:  publicKey.HashAlgo.String(),
+		Weight:    publicKey.Weight,
+		SeqNumber: publicKey.SeqNumber,
+		Revoked:   publicKey.Revoked,
+	}, nil
+}
+
+// DecodeSlab decodes the value of a slab register into an atree slab with Cadence storables.
+func DecodeSlab(key RegisterKey, value flow.RegisterValue) (atree.Slab, error) {
+	var id atree.StorageID
+	copy(id.Address[:], key.Owner)
+	copy(id.Index[:], key.Key[1:])
+
+	return atree.DecodeSlab(
+		id,
+		value,
+		interpreter.CBORDecMode,
+		func(decoder *cbor.StreamDecoder, storableSlabStorageID atree.StorageID) (atree.Storable, error) {
+			return interpreter.DecodeStorable(decoder, storableSlabStorageID, nil)
+		},
+		func(decoder *cbor.StreamDecoder) (atree.TypeInfo, error) {
+			return interpreter.DecodeTypeInfo(decoder, nil)
+		},
+	)
+}
+
+// SlabRegisterKey is the key of the register the slab with the storage ID is stored in.
+func SlabRegisterKey(id atree.StorageID) RegisterKey {
+	return RegisterKey{
+		Owner: string(id.Address[:]),
+		Key:   "$" + string(id.Index[:]),
+	}
+}
+
+func decodeSlab(key RegisterKey, value flow.RegisterValue) (RegisterKind, interface{}, error) {
+	slab, err := DecodeSlab(key, value)
+	if err != nil {
+		return RegisterKindUnknown, nil, err
+	}
+
+	decoded := decodedSlab{}
+	for _, storable := range slab.ChildStorables() {
+		decoded.ChildStorables = append(decoded.ChildStorables, storableString(storable))
+	}
+
+	var kind RegisterKind
+	var typeInfo atree.TypeInfo
+	switch s := slab.(type) {
+	case *atree.ArrayDataSlab:
+		kind = RegisterKindArrayDataSlab
+		if extraData := s.ExtraData(); extraData != nil {
+			typeInfo = extraData.TypeInfo
+		}
+	case *atree.ArrayMetaDataSlab:
+		kind = RegisterKindArrayMetaDataSlab
+		if extraData := s.ExtraData(); extraData != nil {
+			typeInfo = extraData.TypeInfo
+		}
+	case *atree.MapDataSlab:
+		kind = RegisterKindMapDataSlab
+		if extraData := s.ExtraData(); extraData != nil {
+			typeInfo = extraData.TypeInfo
+		}
+	case *atree.MapMetaDataSlab:
+		kind = RegisterKindMapMetaDataSlab
+		if extraData := s.ExtraData(); extraData != nil {
+			typeInfo = extraData.TypeInfo
+		}
+	case atree.StorableSlab:
+		kind = RegisterKindStorableSlab
+	default:
+		kind = RegisterKindUnknown
+	}
+	if typeInfo != nil {
+		decoded.Type = typeInfoString(typeInfo)
+	}
+	return kind, decoded, nil
+}
+
+// storableString returns the storable, with references to other slabs shown as their register key.
+func storableString(storable atree.Storable) string {
+	if id, ok := storable.(atree.StorageIDStorable); ok {
+		return "$" + hex.EncodeToString(id.Index[:])
+	}
+	return fmt.Sprint(storable)
+}
+
+// typeInfoString returns the static type of an array or map, or the type ID of a composite.
+// The maps of the storage domains have no type.
+func typeInfoString(typeInfo atree.TypeInfo) string {
+	switch typeInfo := typeInfo.(type) {
+	case interpreter.StaticType:
+		return typeInfo.String()
+	case interpreter.EmptyTypeInfo:
+		return "storage map"
+	}
+	typeID, err := compositeTypeID(typeInfo)
+	if err != nil {
+		return fmt.Sprint(typeInfo)
+	}
+	return typeID
+}
+
+// compositeTypeID decodes the location and qualified identifier of a composite type info,
+// which are not exported, by encoding it again.
+func compositeTypeID(typeInfo atree.TypeInfo) (string, error) {
+	var buf bytes.Buffer
+	encoder := interpreter.CBOREncMode.NewStreamEncoder(&buf)
+	err := typeInfo.Encode(encoder)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return "", err
+	}
+
+	decoder := interpreter.CBORDecMode.NewByteStreamDecoder(buf.Bytes())
+	tag, err := decoder.DecodeTagNumber()
+	if err != nil {
+		return "", err
+	}
+	if tag != interpreter.CBORTagCompositeValue {
+		return "", fmt.Errorf("unexpected type info CBOR tag: %d", tag)
+	}
+	_, err = decoder.DecodeArrayHead()
+	if err != nil {
+		return "", err
+	}
+	location, err := interpreter.NewLocationDecoder(decoder, nil).DecodeLocation()
+	if err != nil {
+		return "", err
+	}
+	qualifiedIdentifier, err := decoder.DecodeString()
+	if err != nil {
+		return "", err
+	}
+	return string(common.NewTypeIDFromQualifiedName(nil, location, qualifiedIdentifier)), nil
+}
+
+func (r *RegisterDecoder) Close() error {
+	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r.decoded, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.filename, data, 0644)
+}
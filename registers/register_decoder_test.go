@@ -0,0 +1,201 @@
+package registers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/onflow/atree"
+	"github.com/onflow/cadence/runtime/interpreter"
+	"github.com/onflow/flow-go/crypto"
+	"github.com/onflow/flow-go/crypto/hash"
+	"github.com/onflow/flow-go/fvm/environment"
+	"github.com/onflow/flow-go/fvm/state"
+	"github.com/onflow/flow-go/model/flow"
+)
+
+func TestDecodeRegister(t *testing.T) {
+	owner := string(flow.HexToAddress("1654653399040a61").Bytes())
+
+	status := environment.NewAccountStatus()
+	status.SetStorageUsed(1234)
+	status.SetStorageIndex(atree.StorageIndex{0, 0, 0, 0, 0, 0, 0, 5})
+	status.SetPublicKeyCount(2)
+
+	contractNames, err := interpreter.CBOREncMode.Marshal([]string{"A", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSAP256, bytes.Repeat([]byte{1}, crypto.KeyGenSeedMinLenECDSAP256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := flow.EncodeAccountPublicKey(flow.AccountPublicKey{
+		PublicKey: privateKey.PublicKey(),
+		SignAlgo:  crypto.ECDSAP256,
+		HashAlgo:  hash.SHA3_256,
+		Weight:    1000,
+		SeqNumber: 7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		key             RegisterKey
+		value           flow.RegisterValue
+		expectedKind    RegisterKind
+		expectedContent interface{}
+		expectError     bool
+	}{
+		{
+			name:         "empty value",
+			key:          RegisterKey{Owner: owner, Key: "storage_used"},
+			value:        nil,
+			expectedKind: RegisterKindUnknown,
+		},
+		{
+			name:         "unknown key",
+			key:          RegisterKey{Owner: owner, Key: "something"},
+			value:        flow.RegisterValue{1},
+			expectedKind: RegisterKindUnknown,
+		},
+		{
+			name:         "account status",
+			key:          RegisterKey{Owner: owner, Key: state.KeyAccountStatus},
+			value:        status.ToBytes(),
+			expectedKind: RegisterKindAccountStatus,
+			expectedContent: decodedAccountStatus{
+				StorageUsed:    1234,
+				StorageIndex:   "$0000000000000005",
+				PublicKeyCount: 2,
+			},
+		},
+		{
+			name:         "invalid account status",
+			key:          RegisterKey{Owner: owner, Key: state.KeyAccountStatus},
+			value:        flow.RegisterValue{1, 2},
+			expectedKind: RegisterKindAccountStatus,
+			expectError:  true,
+		},
+		{
+			name:            "storage domain",
+			key:             RegisterKey{Owner: owner, Key: "storage"},
+			value:           flow.RegisterValue{0, 0, 0, 0, 0, 0, 0, 3},
+			expectedKind:    RegisterKindStorageDomain,
+			expectedContent: decodedStorageDomain{StorageIndex: "$0000000000000003"},
+		},
+		{
+			name:            "contract code",
+			key:             RegisterKey{Owner: owner, Key: "code.Test"},
+			value:           flow.RegisterValue("pub contract Test {}"),
+			expectedKind:    RegisterKindContractCode,
+			expectedContent: "pub contract Test {}",
+		},
+		{
+			name:            "contract names",
+			key:             RegisterKey{Owner: owner, Key: "contract_names"},
+			value:           contractNames,
+			expectedKind:    RegisterKindContractNames,
+			expectedContent: []string{"A", "B"},
+		},
+		{
+			name:         "public key",
+			key:          RegisterKey{Owner: owner, Key: "public_key_3"},
+			value:        publicKey,
+			expectedKind: RegisterKindPublicKey,
+			expectedContent: decodedPublicKey{
+				Index:     3,
+				PublicKey: privateKey.PublicKey().String(),
+				SignAlgo:  crypto.ECDSAP256.String(),
+				HashAlgo:  hash.SHA3_256.String(),
+				Weight:    1000,
+				SeqNumber: 7,
+			},
+		},
+		{
+			name:         "invalid public key index",
+			key:          RegisterKey{Owner: owner, Key: "public_key_x"},
+			value:        publicKey,
+			expectedKind: RegisterKindPublicKey,
+			expectError:  true,
+		},
+		{
+			name:         "invalid slab",
+			key:          RegisterKey{Owner: owner, Key: "$\x00\x00\x00\x00\x00\x00\x00\x01"},
+			value:        flow.RegisterValue{0xff},
+			expectedKind: RegisterKindUnknown,
+			expectError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded := DecodeRegister(test.key, test.value)
+
+			readable := test.key.ToReadable()
+			if decoded.Owner != readable.Owner || decoded.Key != readable.Key {
+				t.Fatalf("expected register %s/%s, got %s/%s", readable.Owner, readable.Key, decoded.Owner, decoded.Key)
+			}
+			if decoded.Size != len(test.value) {
+				t.Fatalf("expected size %d, got %d", len(test.value), decoded.Size)
+			}
+			if decoded.Kind != test.expectedKind {
+				t.Fatalf("expected kind %q, got %q", test.expectedKind, decoded.Kind)
+			}
+			if test.expectError != (decoded.Error != "") {
+				t.Fatalf("expected error: %v, got %q", test.expectError, decoded.Error)
+			}
+			if !reflect.DeepEqual(decoded.Content, test.expectedContent) {
+				t.Fatalf("expected content %#v, got %#v", test.expectedContent, decoded.Content)
+			}
+		})
+	}
+}
+
+func TestDecodeRegisterSlabs(t *testing.T) {
+	values, rootKey := slabRegisters(t)
+
+	dataSlabs := 0
+	for key, value := range values {
+		decoded := DecodeRegister(key, value)
+		if decoded.Error != "" {
+			t.Fatalf("register %v: %s", key, decoded.Error)
+		}
+		content, ok := decoded.Content.(decodedSlab)
+		if !ok {
+			t.Fatalf("register %v: expected a decoded slab, got %#v", key, decoded.Content)
+		}
+
+		if key == rootKey {
+			if decoded.Kind != RegisterKindArrayMetaDataSlab {
+				t.Fatalf("expected the root slab to be a %q, got %q", RegisterKindArrayMetaDataSlab, decoded.Kind)
+			}
+			if content.Type != "[UInt64]" {
+				t.Fatalf("expected the root slab type [UInt64], got %q", content.Type)
+			}
+			if len(content.ChildStorables) != len(values)-1 {
+				t.Fatalf("expected %d child slabs, got %d", len(values)-1, len(content.ChildStorables))
+			}
+			for _, child := range content.ChildStorables {
+				if _, ok := values[RegisterKey{Owner: rootKey.ToReadable().Owner, Key: child}.ToMangled()]; !ok {
+					t.Fatalf("child slab %s is not a slab of the array", child)
+				}
+			}
+			continue
+		}
+
+		if decoded.Kind != RegisterKindArrayDataSlab {
+			t.Fatalf("expected a %q, got %q", RegisterKindArrayDataSlab, decoded.Kind)
+		}
+		if content.Type != "" {
+			t.Fatalf("expected only the root slab to have a type, got %q", content.Type)
+		}
+		dataSlabs++
+	}
+	if dataSlabs == 0 {
+		t.Fatal("expected array data slabs")
+	}
+}
@@ -9,3 +9,7 @@ type RegisterGetRegisterFunc func(string, string) (flow.RegisterValue, error)
 type RegisterGetWrapper interface {
 	Wrap(RegisterGetRegisterFunc) RegisterGetRegisterFunc
 }
+
+// RegisterReadListener is notified of every register read, with the readable register key
+// and the number of bytes read.
+type RegisterReadListener func(key RegisterKey, read int)
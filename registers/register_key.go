@@ -14,8 +14,13 @@ func (key RegisterKey) IsSlab() bool {
 	return len(key.Key) > 0 && key.Key[0] == '$'
 }
 
+// ToReadable returns the key with the owner as a hex address and slab keys hex encoded.
+// Global registers have no owner, their owner stays empty so that ToMangled restores the key.
 func (key RegisterKey) ToReadable() RegisterKey {
-	a := flow.BytesToAddress([]byte(key.Owner))
+	owner := ""
+	if key.Owner != "" {
+		owner = flow.BytesToAddress([]byte(key.Owner)).Hex()
+	}
 	var keyString string
 
 	if key.IsSlab() {
@@ -25,13 +30,17 @@ func (key RegisterKey) ToReadable() RegisterKey {
 	}
 
 	return RegisterKey{
-		Owner: a.Hex(),
+		Owner: owner,
 		Key:   keyString,
 	}
 }
 
+// ToMangled is the inverse of ToReadable.
 func (key RegisterKey) ToMangled() RegisterKey {
-	a := flow.HexToAddress(key.Owner)
+	owner := ""
+	if key.Owner != "" {
+		owner = string(flow.HexToAddress(key.Owner).Bytes())
+	}
 	keyString := key.Key
 	if key.IsSlab() {
 		decoded, err := hex.DecodeString(key.Key[1:])
@@ -41,7 +50,7 @@ func (key RegisterKey) ToMangled() RegisterKey {
 	}
 
 	return RegisterKey{
-		Owner: string(a.Bytes()),
+		Owner: owner,
 		Key:   keyString,
 	}
 }
@@ -11,8 +11,9 @@ import (
 )
 
 type registerReadEntry struct {
-	key  RegisterKey
-	read int
+	key    RegisterKey
+	read   int
+	caller string
 }
 
 func (e registerReadEntry) String() string {
@@ -22,6 +23,8 @@ func (e registerReadEntry) String() string {
 type RemoteRegisterReadTracker struct {
 	registerRead []registerReadEntry
 	filename     string
+	listeners    []RegisterReadListener
+	getCaller    func() string
 
 	log zerolog.Logger
 }
@@ -45,15 +48,34 @@ func (r *RemoteRegisterReadTracker) Wrap(inner RegisterGetRegisterFunc) Register
 			return nil, err
 		}
 
+		caller := ""
+		if r.getCaller != nil {
+			caller = r.getCaller()
+		}
 		r.registerRead = append(r.registerRead, registerReadEntry{
-			key:  k,
-			read: len(val),
+			key:    k,
+			read:   len(val),
+			caller: caller,
 		})
+		for _, listener := range r.listeners {
+			listener(k, len(val))
+		}
 
 		return val, nil
 	}
 }
 
+// AddListener adds a listener that is notified of the tracked register reads.
+func (r *RemoteRegisterReadTracker) AddListener(listener RegisterReadListener) {
+	r.listeners = append(r.listeners, listener)
+}
+
+// SetCallerFunc sets the function that tells what caused a register read.
+// The caller is recorded with every read.
+func (r *RemoteRegisterReadTracker) SetCallerFunc(getCaller func() string) {
+	r.getCaller = getCaller
+}
+
 func (r *RemoteRegisterReadTracker) Close() error {
 	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
 	if err != nil {
@@ -73,13 +95,13 @@ func (r *RemoteRegisterReadTracker) Close() error {
 
 	csvwriter := csv.NewWriter(csvFile)
 	defer csvwriter.Flush()
-	err = csvwriter.Write([]string{"# Sequence", "Owner", "Key", "bytes"})
+	err = csvwriter.Write([]string{"# Sequence", "Owner", "Key", "bytes", "caller"})
 	if err != nil {
 		return err
 	}
 	for n, read := range r.registerRead {
 
-		err := csvwriter.Write([]string{strconv.Itoa(n + 1), read.key.Owner, read.key.Key, strconv.Itoa(read.read)})
+		err := csvwriter.Write([]string{strconv.Itoa(n + 1), read.key.Owner, read.key.Key, strconv.Itoa(read.read), read.caller})
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,53 @@
+package registers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+)
+
+// LoadRegisterTrace reads the registers listed in a register trace,
+// like registers_read.csv or registers_written.csv of a previous run.
+// The registers are returned deduplicated, in the order they first appear in the trace.
+// Global registers have an empty owner in the trace, like in the register cache and offline bundles.
+// The zero address is a different owner.
+func LoadRegisterTrace(filename string) ([]RegisterKey, error) {
+	csvFile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = csvFile.Close() }()
+
+	csvLines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(csvLines) == 0 {
+		return nil, nil
+	}
+
+	ownerColumn, keyColumn := -1, -1
+	for i, column := range csvLines[0] {
+		switch column {
+		case "Owner":
+			ownerColumn = i
+		case "Key":
+			keyColumn = i
+		}
+	}
+	if ownerColumn < 0 || keyColumn < 0 {
+		return nil, fmt.Errorf("register trace %s has no Owner and Key columns", filename)
+	}
+
+	seen := make(map[RegisterKey]struct{})
+	keys := make([]RegisterKey, 0, len(csvLines)-1)
+	for _, line := range csvLines[1:] {
+		key := RegisterKey{line[ownerColumn], line[keyColumn]}.ToMangled()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
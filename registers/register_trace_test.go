@@ -0,0 +1,50 @@
+package registers
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+)
+
+func TestRegisterTraceRoundTrip(t *testing.T) {
+	address := flow.HexToAddress("1654653399040a61")
+	reads := []RegisterKey{
+		// global registers have no owner
+		{Owner: "", Key: "uuid"},
+		{Owner: string(address.Bytes()), Key: "storage_used"},
+		{Owner: string(address.Bytes()), Key: "$\x00\x00\x00\x00\x00\x00\x00\x01"},
+		{Owner: "", Key: "uuid"},
+		// the zero address is not the global owner
+		{Owner: string(flow.EmptyAddress.Bytes()), Key: "uuid"},
+	}
+
+	directory := t.TempDir()
+	tracker := NewRemoteRegisterReadTracker(directory, zerolog.Nop())
+	get := tracker.Wrap(func(string, string) (flow.RegisterValue, error) {
+		return flow.RegisterValue{1}, nil
+	})
+	for _, key := range reads {
+		_, err := get(key.Owner, key.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tracker.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadRegisterTrace(filepath.Join(directory, "registers_read.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deduplicated, in the order of the first read
+	expected := []RegisterKey{reads[0], reads[1], reads[2], reads[4]}
+	if fmt.Sprintf("%q", keys) != fmt.Sprintf("%q", expected) {
+		t.Fatalf("expected %q, got %q", expected, keys)
+	}
+}
@@ -0,0 +1,97 @@
+package registers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type registerWriteEntry struct {
+	key      RegisterKey
+	oldBytes int
+	newBytes int
+	deleted  bool
+}
+
+func (e registerWriteEntry) String() string {
+	return fmt.Sprintf("%v: %v -> %v bytes", e.key, e.oldBytes, e.newBytes)
+}
+
+type RemoteRegisterWriteTracker struct {
+	registerWrite []registerWriteEntry
+	filename      string
+
+	log zerolog.Logger
+}
+
+func NewRemoteRegisterWriteTracker(directory string, log zerolog.Logger) *RemoteRegisterWriteTracker {
+	return &RemoteRegisterWriteTracker{
+		filename:      directory + "/registers_written.csv",
+		registerWrite: []registerWriteEntry{},
+		log:           log,
+	}
+}
+
+// Track records the register updates. The old values are read with getOldValue.
+func (r *RemoteRegisterWriteTracker) Track(
+	ids []flow.RegisterID,
+	values []flow.RegisterValue,
+	getOldValue RegisterGetRegisterFunc,
+) error {
+	for i, id := range ids {
+		oldValue, err := getOldValue(id.Owner, id.Key)
+		if err != nil {
+			return err
+		}
+
+		r.registerWrite = append(r.registerWrite, registerWriteEntry{
+			key:      RegisterKey{id.Owner, id.Key}.ToReadable(),
+			oldBytes: len(oldValue),
+			newBytes: len(values[i]),
+			deleted:  len(values[i]) == 0,
+		})
+	}
+	return nil
+}
+
+func (r *RemoteRegisterWriteTracker) Close() error {
+	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	csvFile, err := os.Create(r.filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := csvFile.Close()
+		if err != nil {
+			r.log.Error().Err(err).Msg("error closing csv file")
+		}
+	}()
+
+	csvwriter := csv.NewWriter(csvFile)
+	defer csvwriter.Flush()
+	err = csvwriter.Write([]string{"Owner", "Key", "old bytes", "new bytes", "deleted"})
+	if err != nil {
+		return err
+	}
+	for _, write := range r.registerWrite {
+		err := csvwriter.Write([]string{
+			write.key.Owner,
+			write.key.Key,
+			strconv.Itoa(write.oldBytes),
+			strconv.Itoa(write.newBytes),
+			strconv.FormatBool(write.deleted),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package registers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+)
+
+type WriteVerificationStatus string
+
+const (
+	// WriteMatch the network wrote the same value as the replay
+	WriteMatch WriteVerificationStatus = "match"
+	// WriteMismatch the network wrote a different value than the replay
+	WriteMismatch WriteVerificationStatus = "mismatch"
+	// WriteMissing the network did not change the register the replay wrote
+	WriteMissing WriteVerificationStatus = "missing"
+)
+
+type registerVerificationEntry struct {
+	key    RegisterKey
+	status WriteVerificationStatus
+}
+
+// RegisterWriteVerifier compares the registers written by the replay
+// to the register values after the transaction was executed on the network.
+//
+// Only the registers the replay wrote are verified, registers that only the network wrote are not found,
+// as the network writes can not be listed. The network values are read at the end of the block,
+// so a later transaction of the block that writes the same registers makes a correct write a mismatch.
+// Verifying the writes of all the block transactions together avoids that.
+type RegisterWriteVerifier struct {
+	verified []registerVerificationEntry
+	counts   map[WriteVerificationStatus]int
+	filename string
+
+	log zerolog.Logger
+}
+
+func NewRegisterWriteVerifier(directory string, log zerolog.Logger) *RegisterWriteVerifier {
+	return &RegisterWriteVerifier{
+		filename: directory + "/registers_verified.csv",
+		verified: []registerVerificationEntry{},
+		counts:   make(map[WriteVerificationStatus]int),
+		log:      log,
+	}
+}
+
+// Verify checks every register update against the values returned by getExecutedValues.
+// getPreviousValue is used to tell apart mismatching writes from writes that did not happen on the network.
+func (v *RegisterWriteVerifier) Verify(
+	ids []flow.RegisterID,
+	values []flow.RegisterValue,
+	getPreviousValue RegisterGetRegisterFunc,
+	getExecutedValues RegisterBatchGetFunc,
+) error {
+	keys := make([]RegisterKey, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, RegisterKey{id.Owner, id.Key})
+	}
+	executedValues, err := getExecutedValues(keys)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		executed := executedValues[i]
+
+		status := WriteMatch
+		if !bytes.Equal(executed, values[i]) {
+			previous, err := getPreviousValue(id.Owner, id.Key)
+			if err != nil {
+				return err
+			}
+			status = WriteMismatch
+			if bytes.Equal(executed, previous) {
+				status = WriteMissing
+			}
+		}
+
+		v.counts[status]++
+		v.verified = append(v.verified, registerVerificationEntry{
+			key:    RegisterKey{id.Owner, id.Key}.ToReadable(),
+			status: status,
+		})
+	}
+	return nil
+}
+
+// Matches returns true if all verified writes match the network execution.
+// It does not mean that the network did not write other registers.
+func (v *RegisterWriteVerifier) Matches() bool {
+	return v.counts[WriteMatch] == len(v.verified)
+}
+
+func (v *RegisterWriteVerifier) Close() error {
+	logEvent := v.log.Info()
+	if !v.Matches() {
+		logEvent = v.log.Warn()
+	}
+	logEvent.
+		Int("match", v.counts[WriteMatch]).
+		Int("mismatch", v.counts[WriteMismatch]).
+		Int("missing", v.counts[WriteMissing]).
+		Msg("Verified register writes against network execution. Registers only the network wrote are not verified.")
+
+	err := os.MkdirAll(filepath.Dir(v.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	csvFile, err := os.Create(v.filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := csvFile.Close()
+		if err != nil {
+			v.log.Error().Err(err).Msg("error closing csv file")
+		}
+	}()
+
+	csvwriter := csv.NewWriter(csvFile)
+	defer csvwriter.Flush()
+	err = csvwriter.Write([]string{"Owner", "Key", "status"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range v.verified {
+		err := csvwriter.Write([]string{entry.key.Owner, entry.key.Key, string(entry.status)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,7 +11,12 @@ import (
 
 type RemoteRegisterFileCache struct {
 	blockHeight uint64
-	registers   map[RegisterKey]flow.RegisterValue
+	chainID     flow.ChainID
+	// inMemory caches are neither loaded from nor written to the cache file
+	inMemory  bool
+	registers map[RegisterKey]flow.RegisterValue
+	// used contains the registers that were requested through the cache
+	used map[RegisterKey]struct{}
 
 	log zerolog.Logger
 }
@@ -20,12 +25,15 @@ var _ RegisterGetWrapper = &RemoteRegisterFileCache{}
 
 func NewRemoteRegisterFileCache(
 	blockHeight uint64,
+	chainID flow.ChainID,
 	log zerolog.Logger,
 ) (*RemoteRegisterFileCache, error) {
 	c := &RemoteRegisterFileCache{
 		blockHeight: blockHeight,
+		chainID:     chainID,
 		log:         log,
 		registers:   make(map[RegisterKey]flow.RegisterValue),
+		used:        make(map[RegisterKey]struct{}),
 	}
 	err := c.open()
 	if err != nil {
@@ -34,10 +42,28 @@ func NewRemoteRegisterFileCache(
 	return c, nil
 }
 
+// NewRemoteRegisterMemoryCache returns a cache that is only kept in memory,
+// it does not load or overwrite the cache file of the block.
+func NewRemoteRegisterMemoryCache(
+	blockHeight uint64,
+	chainID flow.ChainID,
+	log zerolog.Logger,
+) *RemoteRegisterFileCache {
+	return &RemoteRegisterFileCache{
+		blockHeight: blockHeight,
+		chainID:     chainID,
+		inMemory:    true,
+		log:         log,
+		registers:   make(map[RegisterKey]flow.RegisterValue),
+		used:        make(map[RegisterKey]struct{}),
+	}
+}
+
 func (c *RemoteRegisterFileCache) Wrap(registerFunc RegisterGetRegisterFunc) RegisterGetRegisterFunc {
 	return func(owner string, key string) (flow.RegisterValue, error) {
 		val, found := c.registers[RegisterKey{owner, key}]
 		if found {
+			c.used[RegisterKey{owner, key}] = struct{}{}
 			return val, nil
 		}
 		val, err := registerFunc(owner, key)
@@ -45,12 +71,25 @@ func (c *RemoteRegisterFileCache) Wrap(registerFunc RegisterGetRegisterFunc) Reg
 			return nil, err
 		}
 		c.registers[RegisterKey{owner, key}] = val
+		c.used[RegisterKey{owner, key}] = struct{}{}
 		return val, nil
 	}
 }
 
+// UsedRegisters returns the values of the registers that were requested through the cache
+func (c *RemoteRegisterFileCache) UsedRegisters() map[RegisterKey]flow.RegisterValue {
+	used := make(map[RegisterKey]flow.RegisterValue, len(c.used))
+	for key := range c.used {
+		used[key] = c.registers[key]
+	}
+	return used
+}
+
 // Close the cache
 func (c *RemoteRegisterFileCache) Close() error {
+	if c.inMemory {
+		return nil
+	}
 	// overwrite existing file
 	// and dump registers to file as a csv
 	filename := c.getFilename()
@@ -135,7 +174,31 @@ func (c *RemoteRegisterFileCache) decodeRegisterValue(value string) (flow.Regist
 	return decoded, nil
 }
 
-// getFilename
+// getFilename includes the chain ID so caches from different networks never collide
 func (c *RemoteRegisterFileCache) getFilename() string {
-	return fmt.Sprintf("block-%d-cache.csv", c.blockHeight)
+	return fmt.Sprintf("%s-block-%d-cache.csv", c.chainID, c.blockHeight)
+}
+
+// Prefetch loads the registers that are not cached yet using getMany
+func (c *RemoteRegisterFileCache) Prefetch(keys []RegisterKey, getMany RegisterBatchGetFunc) error {
+	missing := make([]RegisterKey, 0, len(keys))
+	for _, key := range keys {
+		if _, found := c.registers[key]; !found {
+			missing = append(missing, key)
+		}
+	}
+
+	c.log.Info().
+		Int("registers", len(keys)).
+		Int("missing", len(missing)).
+		Msg("prefetching registers")
+
+	values, err := getMany(missing)
+	if err != nil {
+		return err
+	}
+	for i, key := range missing {
+		c.registers[key] = values[i]
+	}
+	return nil
 }
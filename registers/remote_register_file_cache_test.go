@@ -0,0 +1,63 @@
+package registers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+)
+
+func TestRemoteRegisterMemoryCacheDoesNotUseFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	key := RegisterKey{Owner: "owner", Key: "key"}
+	fileCache, err := NewRemoteRegisterFileCache(1, flow.Mainnet, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fileCache.Wrap(func(string, string) (flow.RegisterValue, error) {
+		return flow.RegisterValue{1}, nil
+	})(key.Owner, key.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fileCache.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(fileCache.getFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memoryCache := NewRemoteRegisterMemoryCache(1, flow.Mainnet, zerolog.Nop())
+	value, err := memoryCache.Wrap(func(string, string) (flow.RegisterValue, error) {
+		return flow.RegisterValue{2}, nil
+	})(key.Owner, key.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(value) != 1 || value[0] != 2 {
+		t.Fatalf("expected the register not to be loaded from the cache file, got %x", value)
+	}
+	err = memoryCache.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(memoryCache.getFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("expected the cache file not to be overwritten")
+	}
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"fmt"
 	"github.com/google/pprof/profile"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
 	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
 	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/common"
 	"github.com/onflow/cadence/runtime/interpreter"
 	"github.com/onflow/flow-go/fvm/environment"
+	"github.com/onflow/flow-go/fvm/meter"
+	"github.com/onflow/flow-go/fvm/programs"
 	runtime2 "github.com/onflow/flow-go/fvm/runtime"
 	"github.com/onflow/flow-go/fvm/state"
+	"github.com/onflow/flow-go/fvm/utils"
 	"github.com/rs/zerolog"
-	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/onflow/flow-go/fvm"
 	"github.com/onflow/flow-go/model/flow"
@@ -21,26 +28,55 @@ type RemoteDebugger struct {
 	ctx  fvm.Context
 	view state.View
 
+	// queryCtx is used to query the state without profiling the query
+	queryCtx fvm.Context
+
 	profileBuilder *ProfileBuilder
+	pipelineSteps  []*PipelineStep
 }
 
 func NewRemoteDebugger(
 	view *RemoteView,
 	chain flow.Chain,
+	header *flow.Header,
 	directory string,
+	fullPipeline bool,
+	profileFormats []ProfileFormat,
 	logger zerolog.Logger) *RemoteDebugger {
 	vm := fvm.NewVirtualMachine()
 
 	profileBuilder := NewProfileBuilder(
 		directory,
+		profileFormats,
 	)
 
-	// no signature processor here
-	// TODO Maybe we add fee-deduction step as well
+	// by default there is no sequence number check, signature verification or fee deduction
+	processors := []fvm.TransactionProcessor{fvm.NewTransactionInvoker()}
+	if fullPipeline {
+		processors = []fvm.TransactionProcessor{
+			fvm.NewTransactionSequenceNumberChecker(),
+			fvm.NewTransactionVerifier(fvm.AccountKeyWeightThreshold),
+			fvm.NewTransactionInvoker(),
+		}
+	}
+	pipelineSteps := make([]*PipelineStep, 0, len(processors))
+	measuredProcessors := make([]fvm.TransactionProcessor, 0, len(processors))
+	for _, processor := range processors {
+		step := &PipelineStep{
+			Name:      pipelineStepName(processor),
+			processor: processor,
+		}
+		pipelineSteps = append(pipelineSteps, step)
+		measuredProcessors = append(measuredProcessors, step)
+	}
+
 	ctx := fvm.NewContext(
 		fvm.WithLogger(logger),
 		fvm.WithChain(chain),
-		fvm.WithTransactionProcessors(fvm.NewTransactionInvoker()),
+		fvm.WithBlockHeader(header),
+		fvm.WithTransactionProcessors(measuredProcessors...),
+		fvm.WithTransactionFeesEnabled(fullPipeline),
+		fvm.WithCadenceLogging(true),
 		fvm.WithReusableCadenceRuntimePool(runtime2.NewReusableCadenceRuntimePool(
 			1,
 			runtime2.ReusableCadenceRuntimePoolConfig{
@@ -49,23 +85,207 @@ func NewRemoteDebugger(
 		)),
 	)
 
+	// queries should not fail because of the limits of the network
+	queryCtx := fvm.NewContext(
+		fvm.WithLogger(zerolog.Nop()),
+		fvm.WithChain(chain),
+		fvm.WithMemoryAndInteractionLimitsDisabled(),
+	)
+
 	return &RemoteDebugger{
 		ctx:            ctx,
+		queryCtx:       queryCtx,
 		vm:             vm,
 		view:           view,
 		profileBuilder: profileBuilder,
+		pipelineSteps:  pipelineSteps,
 	}
 }
 
-// RunTransaction runs the transaction given the latest sealed block data
-func (d *RemoteDebugger) RunTransaction(txBody *flow.TransactionBody) (txErr, processError error) {
-	blockCtx := fvm.NewContextFromParent(d.ctx, fvm.WithBlockHeader(d.ctx.BlockHeader))
-	tx := fvm.Transaction(txBody, 0)
-	err := d.vm.Run(blockCtx, tx, d.view)
+// RunTransaction runs the transaction in the block of the debugger block header
+func (d *RemoteDebugger) RunTransaction(txBody *flow.TransactionBody, txIndex uint32) (tx *fvm.TransactionProcedure, processError error) {
+	tx = fvm.Transaction(txBody, txIndex)
+	err := d.vm.Run(d.ctx, tx, d.view)
 	if err != nil {
 		return nil, err
 	}
-	return tx.Err, nil
+	return tx, nil
+}
+
+// ProfileBuilder returns the builder of the execution profile of the transaction.
+func (d *RemoteDebugger) ProfileBuilder() *ProfileBuilder {
+	return d.profileBuilder
+}
+
+// PipelineSteps returns the transaction processing steps with the computation they used.
+func (d *RemoteDebugger) PipelineSteps() []*PipelineStep {
+	return d.pipelineSteps
+}
+
+const accountBalanceScript = `
+pub fun main(address: Address): UFix64 {
+	return getAccount(address).balance
+}
+`
+
+// GetAccountBalance returns the FLOW balance of the account in the given view.
+// The query is not profiled.
+func (d *RemoteDebugger) GetAccountBalance(view state.View, address flow.Address) (cadence.UFix64, error) {
+	value, err := d.runQuery(view, accountBalanceScript, cadence.NewAddress(address))
+	if err != nil {
+		return 0, err
+	}
+	balance, ok := value.(cadence.UFix64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected balance type: %T", value)
+	}
+	return balance, nil
+}
+
+const storageCapacityScript = `
+pub fun main(addresses: [Address]): [UInt64] {
+	let capacities: [UInt64] = []
+	for address in addresses {
+		capacities.append(getAccount(address).storageCapacity)
+	}
+	return capacities
+}
+`
+
+// GetStorageCapacities returns the storage capacity in bytes of every account in the given view.
+// The query is not profiled.
+func (d *RemoteDebugger) GetStorageCapacities(view state.View, addresses []flow.Address) ([]uint64, error) {
+	cadenceAddresses := make([]cadence.Value, 0, len(addresses))
+	for _, address := range addresses {
+		cadenceAddresses = append(cadenceAddresses, cadence.NewAddress(address))
+	}
+	value, err := d.runQuery(view, storageCapacityScript, cadence.NewArray(cadenceAddresses))
+	if err != nil {
+		return nil, err
+	}
+	array, ok := value.(cadence.Array)
+	if !ok || len(array.Values) != len(addresses) {
+		return nil, fmt.Errorf("unexpected storage capacities: %v", value)
+	}
+	capacities := make([]uint64, 0, len(array.Values))
+	for _, capacity := range array.Values {
+		bytes, ok := capacity.(cadence.UInt64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected storage capacity type: %T", capacity)
+		}
+		capacities = append(capacities, uint64(bytes))
+	}
+	return capacities, nil
+}
+
+const executionParametersScript = `
+pub fun main(service: Address): {String: AnyStruct?} {
+	let account = getAuthAccount(service)
+	return {
+		"memoryLimit": account.copy<UInt64>(from: /storage/executionMemoryLimit),
+		"executionEffortWeights": account.copy<{UInt64: UInt64}>(from: /storage/executionEffortWeights),
+		"memoryWeights": account.copy<{UInt64: UInt64}>(from: /storage/executionMemoryWeights)
+	}
+}
+`
+
+// ExecutionParameters are the metering parameters of the network stored in the service account.
+type ExecutionParameters struct {
+	// MemoryLimit is 0 if there is no memory limit set
+	MemoryLimit uint64
+	// ComputationWeights are the execution effort weights,
+	// with meter.MeterExecutionInternalPrecisionBytes of fractional precision
+	ComputationWeights map[uint64]uint64
+	MemoryWeights      map[uint64]uint64
+}
+
+// GetExecutionParameters returns the execution parameters stored in the service account in the given view.
+// Weights that are not stored fall back to the fvm defaults, the same way the fvm does.
+// The query is not profiled.
+func (d *RemoteDebugger) GetExecutionParameters(view state.View) (*ExecutionParameters, error) {
+	value, err := d.runQuery(view, executionParametersScript, cadence.NewAddress(d.ctx.Chain.ServiceAddress()))
+	if err != nil {
+		return nil, err
+	}
+	dictionary, ok := value.(cadence.Dictionary)
+	if !ok {
+		return nil, fmt.Errorf("unexpected execution parameters type: %T", value)
+	}
+	stored := make(map[string]cadence.Value, len(dictionary.Pairs))
+	for _, pair := range dictionary.Pairs {
+		key, ok := pair.Key.(cadence.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected execution parameter key type: %T", pair.Key)
+		}
+		if optional, ok := pair.Value.(cadence.Optional); ok {
+			if optional.Value == nil {
+				continue
+			}
+			stored[string(key)] = optional.Value
+			continue
+		}
+		stored[string(key)] = pair.Value
+	}
+
+	params := &ExecutionParameters{
+		ComputationWeights: make(map[uint64]uint64, len(meter.DefaultComputationWeights)),
+		MemoryWeights:      make(map[uint64]uint64, len(meter.DefaultMemoryWeights)),
+	}
+	for kind, weight := range meter.DefaultComputationWeights {
+		params.ComputationWeights[uint64(kind)] = weight
+	}
+	for kind, weight := range meter.DefaultMemoryWeights {
+		params.MemoryWeights[uint64(kind)] = weight
+	}
+
+	if value, ok := stored["memoryLimit"]; ok {
+		limit, ok := value.(cadence.UInt64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected memory limit type: %T", value)
+		}
+		params.MemoryLimit = uint64(limit)
+	}
+	if value, ok := stored["executionEffortWeights"]; ok {
+		weights, ok := utils.CadenceValueToWeights(value)
+		if !ok {
+			return nil, fmt.Errorf("unexpected execution effort weights type: %T", value)
+		}
+		for kind, weight := range weights {
+			params.ComputationWeights[uint64(kind)] = weight
+		}
+	}
+	if value, ok := stored["memoryWeights"]; ok {
+		weights, ok := utils.CadenceValueToWeights(value)
+		if !ok {
+			return nil, fmt.Errorf("unexpected memory weights type: %T", value)
+		}
+		for kind, weight := range weights {
+			params.MemoryWeights[uint64(kind)] = weight
+		}
+	}
+	return params, nil
+}
+
+// runQuery runs a script in the given view without profiling it.
+func (d *RemoteDebugger) runQuery(view state.View, code string, arguments ...cadence.Value) (cadence.Value, error) {
+	encodedArguments := make([][]byte, 0, len(arguments))
+	for _, argument := range arguments {
+		encoded, err := jsoncdc.Encode(argument)
+		if err != nil {
+			return nil, err
+		}
+		encodedArguments = append(encodedArguments, encoded)
+	}
+	scriptCtx := fvm.NewContextFromParent(d.queryCtx, fvm.WithBlockHeader(d.ctx.BlockHeader))
+	script := fvm.Script([]byte(code)).WithArguments(encodedArguments...)
+	err := d.vm.Run(scriptCtx, script, view)
+	if err != nil {
+		return nil, err
+	}
+	if script.Err != nil {
+		return nil, script.Err
+	}
+	return script.Value, nil
 }
 
 func (d *RemoteDebugger) RunScript(code []byte, arguments [][]byte) (value cadence.Value, scriptError, processError error) {
@@ -82,58 +302,133 @@ func (d *RemoteDebugger) Close() error {
 	return d.profileBuilder.Close()
 }
 
+// PipelineStep is a transaction processor that measures the computation used by the processor.
+type PipelineStep struct {
+	Name            string
+	ComputationUsed uint64
+
+	processor fvm.TransactionProcessor
+}
+
+var _ fvm.TransactionProcessor = &PipelineStep{}
+
+func (s *PipelineStep) Process(
+	ctx fvm.Context,
+	proc *fvm.TransactionProcedure,
+	txnState *state.TransactionState,
+	programs *programs.TransactionPrograms,
+) error {
+	before := txnState.TotalComputationUsed()
+	err := s.processor.Process(ctx, proc, txnState, programs)
+	s.ComputationUsed += uint64(txnState.TotalComputationUsed() - before)
+	return err
+}
+
+func pipelineStepName(processor fvm.TransactionProcessor) string {
+	switch processor.(type) {
+	case *fvm.TransactionSequenceNumberChecker:
+		return "sequence number check"
+	case *fvm.TransactionVerifier:
+		return "signature verification"
+	case fvm.TransactionInvoker:
+		return "transaction invocation"
+	default:
+		return fmt.Sprintf("%T", processor)
+	}
+}
+
 type ProfileBuilder struct {
-	Profile            *profile.Profile
-	profileFunctionMap map[string]uint64
+	Profile *profile.Profile
+	// UnclassifiedFrames is the number of profile functions that have a fallback name
+	UnclassifiedFrames int
+
+	profileFunctionMap map[string]*profile.Function
 	lastComputation    uint64
-	profileLocationMap map[string]uint64
+	lastMemory         uint64
+
+	// the call stack of the last statement, to attribute register reads to
+	lastInterpreter *interpreter.Interpreter
+	lastLocations   []*profile.Location
+	// lastInvocations are where the frames of the call stack of the last statement were invoked, root first
+	lastInvocations []interpreter.LocationRange
+	// readSamples are the register reads, by the call stack that caused them
+	readSamples        []*profile.Sample
+	profileLocationMap map[profileLocationKey]*profile.Location
+
+	// logEnv is the environment of the program logs, logCount the number of its logs passed to the log listeners
+	logEnv       environment.Environment
+	logCount     int
+	logListeners []func(caller string, message string)
 
-	nextLocID uint64
-	nextFunID uint64
 	directory string
+	formats   []ProfileFormat
+	// trace is nil if the trace format is not written
+	trace *TraceBuilder
+}
+
+// profileLocationKey identifies a location by the function and the position in the function.
+// pprof lines have no column, but the column keeps statements on the same line apart.
+type profileLocationKey struct {
+	functionID uint64
+	line       int64
+	column     int64
 }
 
-func NewProfileBuilder(directory string) *ProfileBuilder {
+func NewProfileBuilder(directory string, formats []ProfileFormat) *ProfileBuilder {
 	// https://www.polarsignals.com/blog/posts/2021/08/03/diy-pprof-profiles-using-go/
 	p := &profile.Profile{
 		Function: []*profile.Function{},
 		Location: []*profile.Location{},
 	}
-	p.SampleType = []*profile.ValueType{{
-		Type: "execution effort",
-		Unit: "effort",
-	}}
+	p.SampleType = []*profile.ValueType{
+		{
+			Type: "execution effort",
+			Unit: "effort",
+		},
+		{
+			Type: "memory",
+			Unit: "bytes",
+		},
+	}
+	// pprof shows the last sample type by default
+	p.DefaultSampleType = "execution effort"
 
-	return &ProfileBuilder{
+	builder := &ProfileBuilder{
 		Profile:            p,
-		profileFunctionMap: make(map[string]uint64),
-		profileLocationMap: make(map[string]uint64),
+		profileFunctionMap: make(map[string]*profile.Function),
+		profileLocationMap: make(map[profileLocationKey]*profile.Location),
 		directory:          directory,
+		formats:            formats,
+	}
+	for _, format := range formats {
+		if format == ProfileFormatTrace {
+			builder.trace = NewTraceBuilder(directory)
+		}
 	}
+	return builder
 }
 
 func (p *ProfileBuilder) Close() error {
-	filename := p.directory + "/profile.pb.gz"
-	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		err := f.Close()
+	for _, format := range p.formats {
+		var err error
+		switch format {
+		case ProfileFormatPprof:
+			err = p.writePprof()
+			if err == nil {
+				err = p.writeRegisterReadsPprof()
+			}
+		case ProfileFormatFolded:
+			err = p.writeFolded()
+		case ProfileFormatSpeedscope:
+			err = p.writeSpeedscope()
+		case ProfileFormatTrace:
+			err = p.trace.Close(p.lastComputation)
+		default:
+			err = fmt.Errorf("unknown profile format: %s", format)
+		}
 		if err != nil {
-			// log
+			return err
 		}
-	}()
-
-	// Write the profile to the file.
-	err = p.Profile.Write(f)
-	if err != nil {
-		return err
 	}
 	return nil
 }
@@ -145,87 +440,319 @@ func (p *ProfileBuilder) OnCadenceStatement(fvmEnv runtime2.Environment, inter *
 		return
 	}
 
-	newComputation := fvmEnv.(environment.Environment).ComputationUsed()
+	env := fvmEnv.(environment.Environment)
+	newComputation := env.ComputationUsed()
 	computation := newComputation - p.lastComputation
 	p.lastComputation = newComputation
 
-	locationIds := make([]uint64, 0, len(stack))
+	newMemory := env.MemoryEstimate()
+	memory := newMemory - p.lastMemory
+	p.lastMemory = newMemory
 
-	// var lastFrame interpreter.Invocation
+	locations := p.stackLocations(inter, stack, statement.StartPosition())
+	p.lastInterpreter = inter
+	p.lastLocations = locations
+	p.lastInvocations = p.lastInvocations[:0]
 	for _, frame := range stack {
-		// lastFrame = frame
-		fn := p.toFunction(inter, frame)
-		fnIndex, ok := p.profileFunctionMap[p.fnID(fn)]
-		if !ok {
-			p.Profile.Function = append(p.Profile.Function, fn)
-			p.Profile.Location = append(p.Profile.Location,
-				&profile.Location{
-					ID:      p.nextLocID + 1,
-					Address: p.nextLocID + 1,
-					Line: []profile.Line{
-						{
-							Function: fn,
-							Line:     fn.StartLine,
-						},
-					},
-				},
-			)
-			fnIndex = p.nextFunID
-			p.profileFunctionMap[p.fnID(fn)] = p.nextFunID
-			p.profileLocationMap[p.fnID(fn)] = p.nextLocID
-			p.nextFunID++
-			p.nextLocID++
-		}
-		locationIds = append(locationIds, fnIndex)
-	}
-
-	locations := make([]*profile.Location, 0, len(locationIds))
-	// revers iterate locations
-	for i := len(locationIds) - 1; i >= 0; i-- {
-		locations = append(locations, p.Profile.Location[locationIds[i]])
+		p.lastInvocations = append(p.lastInvocations, frame.LocationRange)
 	}
+	// the statement was executed before this is called, so the logs since the last statement are logged by it
+	p.onProgramLogs(env, locations[0])
 
 	p.Profile.Sample = append(p.Profile.Sample, &profile.Sample{
 		Location: locations,
-		Value:    []int64{int64(computation)},
+		Value:    []int64{int64(computation), int64(memory)},
+	})
+	if p.trace != nil {
+		p.trace.OnStatement(locations, newComputation)
+	}
+}
+
+// stackLocations returns the locations of the call stack, leaf first.
+// The leaf frame is executing the code at leafPosition,
+// every other frame is executing the invocation of the frame after it.
+func (p *ProfileBuilder) stackLocations(
+	inter *interpreter.Interpreter,
+	stack []interpreter.Invocation,
+	leafPosition ast.Position,
+) []*profile.Location {
+	locations := make([]*profile.Location, 0, len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		position := leafPosition
+		codeLocation := inter.Location
+		if i < len(stack)-1 {
+			position = invocationPosition(stack[i+1])
+			codeLocation = stack[i+1].LocationRange.Location
+		}
+		fn := p.function(inter, stack[i], codeLocation)
+		locations = append(locations, p.location(fn, position))
+	}
+	return locations
+}
+
+// currentLocations returns the locations of the call stack that is executing right now, leaf first.
+// Outside of Cadence execution, e.g. when the fvm reads registers itself, the only location is the fvm.
+//
+// Only the position of the statements is known, so the frames that still match the frames of the last statement
+// reuse its locations. A function invoked since the last statement has not executed a statement yet,
+// so the frame invoking it is the leaf, at the invocation.
+func (p *ProfileBuilder) currentLocations() []*profile.Location {
+	if p.lastInterpreter == nil || len(p.lastInterpreter.CallStack()) == 0 {
+		return []*profile.Location{p.location(p.fvmFunction(), ast.Position{})}
+	}
+	stack := p.lastInterpreter.CallStack()
+
+	matching := 0
+	for matching < len(stack) &&
+		matching < len(p.lastInvocations) &&
+		stack[matching].LocationRange == p.lastInvocations[matching] {
+		matching++
+	}
+	if matching == len(stack) {
+		// no function was invoked since the last statement, but functions may have returned,
+		// the frames that invoked them are executing the invocations
+		return p.lastLocations[len(p.lastInvocations)-len(stack):]
+	}
+	if len(stack) == 1 {
+		// the root frame was invoked by the runtime and has not executed a statement yet
+		return []*profile.Location{p.location(p.fvmFunction(), ast.Position{})}
+	}
+
+	locations := make([]*profile.Location, 0, len(stack)-1)
+	for i := len(stack) - 2; i >= 0; i-- {
+		if i+1 < matching {
+			// the frame and the frame it invoked are the frames of the last statement
+			locations = append(locations, p.lastLocations[len(p.lastInvocations)-1-i])
+			continue
+		}
+		fn := p.function(p.lastInterpreter, stack[i], stack[i+1].LocationRange.Location)
+		locations = append(locations, p.location(fn, invocationPosition(stack[i+1])))
+	}
+	return locations
+}
+
+// Caller returns the function that is executing right now and the position in it,
+// e.g. "FlowToken.Vault.withdraw 0x1654653399040a61/FlowToken.cdc:57", or "fvm" outside of Cadence execution.
+func (p *ProfileBuilder) Caller() string {
+	return callerName(p.currentLocations()[0])
+}
+
+func callerName(location *profile.Location) string {
+	fn := locationFunction(location)
+	if fn.Filename == "" {
+		return fn.Name
+	}
+	return fmt.Sprintf("%s %s:%d", fn.Name, fn.Filename, location.Line[0].Line)
+}
+
+// AddLogListener adds a listener that is called with every Cadence program log
+// and the caller of the statement that emitted it, in the format of Caller.
+// Cadence logging has to be enabled for the program logs to be recorded.
+func (p *ProfileBuilder) AddLogListener(listener func(caller string, message string)) {
+	p.logListeners = append(p.logListeners, listener)
+}
+
+// FlushLogs passes the program logs that were not passed yet to the log listeners, attributed to the fvm.
+// Call it after the transaction ran.
+func (p *ProfileBuilder) FlushLogs() {
+	if p.logEnv != nil {
+		p.onProgramLogs(p.logEnv, p.location(p.fvmFunction(), ast.Position{}))
+	}
+}
+
+// onProgramLogs passes the program logs of env that were not passed yet to the log listeners,
+// with the caller of location.
+func (p *ProfileBuilder) onProgramLogs(env environment.Environment, location *profile.Location) {
+	if env != p.logEnv {
+		// every procedure run has its own environment
+		p.logEnv = env
+		p.logCount = 0
+	}
+	logs := env.Logs()
+	if p.logCount >= len(logs) {
+		return
+	}
+	caller := callerName(location)
+	for ; p.logCount < len(logs); p.logCount++ {
+		for _, listener := range p.logListeners {
+			listener(caller, logs[p.logCount])
+		}
+	}
+}
+
+// OnRegisterRead attributes the bytes read to the call stack that is executing, and adds the read to the trace.
+func (p *ProfileBuilder) OnRegisterRead(key registers.RegisterKey, read int) {
+	p.readSamples = append(p.readSamples, &profile.Sample{
+		Location: p.currentLocations(),
+		Value:    []int64{1, int64(read)},
 	})
+	if p.trace != nil {
+		p.trace.OnRegisterRead(key, read)
+	}
+}
+
+// fvmFunction returns the function register reads outside of Cadence execution are attributed to.
+func (p *ProfileBuilder) fvmFunction() *profile.Function {
+	return p.addFunction(&profile.Function{
+		Name:       "fvm",
+		SystemName: "fvm",
+	})
+}
+
+// function returns the profile function of the frame, adding it to the profile if it is new.
+// codeLocation is the location of the code the frame is executing.
+func (p *ProfileBuilder) function(
+	inter *interpreter.Interpreter,
+	frame interpreter.Invocation,
+	codeLocation common.Location,
+) *profile.Function {
+	fn, classified := p.toFunction(inter, frame, codeLocation)
+	if _, ok := p.profileFunctionMap[p.fnID(fn)]; !ok && !classified {
+		p.UnclassifiedFrames++
+	}
+	return p.addFunction(fn)
+}
+
+// addFunction adds the function to the profile, unless a function with the same ID was already added.
+func (p *ProfileBuilder) addFunction(fn *profile.Function) *profile.Function {
+	existing, ok := p.profileFunctionMap[p.fnID(fn)]
+	if ok {
+		return existing
+	}
+	fn.ID = uint64(len(p.Profile.Function) + 1)
+	p.Profile.Function = append(p.Profile.Function, fn)
+	p.profileFunctionMap[p.fnID(fn)] = fn
+	return fn
+}
+
+// location returns the profile location of the position in the function, adding it to the profile if it is new.
+func (p *ProfileBuilder) location(fn *profile.Function, position ast.Position) *profile.Location {
+	key := profileLocationKey{
+		functionID: fn.ID,
+		line:       int64(position.Line),
+		column:     int64(position.Column),
+	}
+	loc, ok := p.profileLocationMap[key]
+	if ok {
+		return loc
+	}
+	id := uint64(len(p.Profile.Location) + 1)
+	loc = &profile.Location{
+		ID:      id,
+		Address: id,
+		Line: []profile.Line{
+			{
+				Function: fn,
+				Line:     key.line,
+			},
+		},
+	}
+	p.Profile.Location = append(p.Profile.Location, loc)
+	p.profileLocationMap[key] = loc
+	return loc
+}
+
+// invocationPosition returns the position of the invocation in the code of the calling frame.
+func invocationPosition(frame interpreter.Invocation) ast.Position {
+	if frame.LocationRange.HasPosition == nil {
+		return ast.Position{}
+	}
+	return frame.LocationRange.StartPosition()
 }
 
 func (p *ProfileBuilder) fnID(fn *profile.Function) string {
 	return fn.Filename + "_" + fn.Name
 }
 
-func (p *ProfileBuilder) toFunction(inter *interpreter.Interpreter, frame interpreter.Invocation) *profile.Function {
-	filename := frame.Self.StaticType(inter).String()
-	name := ""
-	line := int64(0)
-
-	if frame.LocationRange.HasPosition != nil {
-		switch frame.LocationRange.HasPosition.(type) {
-		case *ast.InvocationExpression:
-			expression := frame.LocationRange.HasPosition.(*ast.InvocationExpression)
-			line = int64(expression.InvokedExpression.StartPosition().Line)
-
-			switch expression.InvokedExpression.(type) {
-			case *ast.MemberExpression:
-				me := expression.InvokedExpression.(*ast.MemberExpression)
-				name = me.Identifier.String()
-			case *ast.IdentifierExpression:
-				ie := expression.InvokedExpression.(*ast.IdentifierExpression)
-				name = ie.Identifier.String()
-			default:
-				panic("")
-			}
-		default:
-			panic("")
+// toFunction returns the function the frame is executing.
+// If the function can not be named from the invocation, a fallback name is used and classified is false.
+func (p *ProfileBuilder) toFunction(
+	inter *interpreter.Interpreter,
+	frame interpreter.Invocation,
+	codeLocation common.Location,
+) (fn *profile.Function, classified bool) {
+	filename := codeFilename(codeLocation)
+	name := "entry point"
+	classified = true
+
+	switch position := frame.LocationRange.HasPosition.(type) {
+	case nil:
+		// invoked by the runtime, e.g. the transaction prepare and execute blocks
+	case *ast.InvocationExpression:
+		name, classified = invokedExpressionName(position.InvokedExpression)
+	case ast.Element:
+		name, classified = elementName(position), false
+	default:
+		name, classified = fmt.Sprintf("unknown %T", position), false
+	}
+
+	// qualify methods with the type they are declared in
+	if frame.Self != nil && frame.LocationRange.HasPosition != nil {
+		qualifier := ""
+		switch staticType := frame.Self.StaticType(inter).(type) {
+		case interpreter.CompositeStaticType:
+			qualifier = staticType.QualifiedIdentifier
+		case interpreter.InterfaceStaticType:
+			qualifier = staticType.QualifiedIdentifier
+		}
+		if qualifier != "" {
+			name = qualifier + "." + name
 		}
 	}
 
+	// the start line is not known, the invocation is in the code of the caller
 	return &profile.Function{
-		ID:         p.nextFunID + 1,
 		Name:       name,
 		SystemName: name,
 		Filename:   filename,
-		StartLine:  line,
+	}, classified
+}
+
+// invokedExpressionName names the function an invoked expression evaluates to.
+func invokedExpressionName(expression ast.Expression) (name string, classified bool) {
+	switch expression := expression.(type) {
+	case *ast.IdentifierExpression:
+		return expression.Identifier.String(), true
+	case *ast.MemberExpression:
+		return expression.Identifier.String(), true
+	case *ast.IndexExpression:
+		name, classified = invokedExpressionName(expression.TargetExpression)
+		return name + "[]", classified
+	case *ast.InvocationExpression:
+		name, classified = invokedExpressionName(expression.InvokedExpression)
+		return name + "()", classified
+	case *ast.ForceExpression:
+		return invokedExpressionName(expression.Expression)
+	case *ast.CastingExpression:
+		return invokedExpressionName(expression.Expression)
+	case *ast.ReferenceExpression:
+		return invokedExpressionName(expression.Expression)
+	case *ast.FunctionExpression:
+		return "anonymous function", true
+	default:
+		return elementName(expression), false
+	}
+}
+
+// elementName is the fallback name of a frame, e.g. "unknown ConditionalExpression".
+// Angle brackets are avoided, as pprof strips them from function names.
+func elementName(element ast.Element) string {
+	return "unknown " + strings.TrimPrefix(element.ElementType().String(), "ElementType")
+}
+
+// codeFilename returns the path of the code at the location,
+// relative to the output directory, as written by the CaptureContractWrapper and the transaction dump.
+func codeFilename(location common.Location) string {
+	switch location := location.(type) {
+	case common.AddressLocation:
+		return filepath.Join(flow.Address(location.Address).HexWithPrefix(), location.Name+".cdc")
+	case common.TransactionLocation:
+		return "transaction.cdc"
+	case common.ScriptLocation:
+		return "script.cdc"
+	case nil:
+		return ""
+	default:
+		return location.String()
 	}
 }
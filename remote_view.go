@@ -5,12 +5,16 @@ import (
 	"github.com/janezpodhostnik/flow-transaction-info/registers"
 	"github.com/onflow/flow-go/fvm/state"
 	"github.com/onflow/flow-go/model/flow"
+	"sort"
 )
 
 type RemoteView struct {
 	Parent *RemoteView
 	Delta  map[string]flow.RegisterValue
 
+	// touched contains all the registers that have been read or written to
+	touched map[string]flow.RegisterID
+
 	getRemoteRegister registers.RegisterGetRegisterFunc
 }
 
@@ -18,15 +22,30 @@ func NewRemoteView(getRemoteRegister registers.RegisterGetRegisterFunc) *RemoteV
 
 	view := &RemoteView{
 		Delta:             make(map[string]flow.RegisterValue),
+		touched:           make(map[string]flow.RegisterID),
 		getRemoteRegister: getRemoteRegister,
 	}
 	return view
 }
 
+// CopyWithSource returns a new view with a copy of the delta and the touched registers of this view
+// that reads the registers which are not in the delta with getRemoteRegister.
+func (v *RemoteView) CopyWithSource(getRemoteRegister registers.RegisterGetRegisterFunc) *RemoteView {
+	view := NewRemoteView(getRemoteRegister)
+	for k, value := range v.Delta {
+		view.Delta[k] = value
+	}
+	for k, id := range v.touched {
+		view.touched[k] = id
+	}
+	return view
+}
+
 func (v *RemoteView) NewChild() state.View {
 	return &RemoteView{
-		Parent: v,
-		Delta:  make(map[string][]byte),
+		Parent:  v,
+		Delta:   make(map[string][]byte),
+		touched: make(map[string]flow.RegisterID),
 	}
 }
 
@@ -40,6 +59,9 @@ func (v *RemoteView) MergeView(o state.View) error {
 	for k, value := range other.Delta {
 		v.Delta[k] = value
 	}
+	for k, id := range other.touched {
+		v.touched[k] = id
+	}
 	return nil
 }
 
@@ -48,11 +70,18 @@ func (v *RemoteView) DropDelta() {
 }
 
 func (v *RemoteView) Set(owner, key string, value flow.RegisterValue) error {
+	v.touch(owner, key)
 	v.Delta[owner+"~"+key] = value
 	return nil
 }
 
 func (v *RemoteView) Get(owner, key string) (flow.RegisterValue, error) {
+	v.touch(owner, key)
+	return v.peek(owner, key)
+}
+
+// peek reads the value without recording the touch, as when used by a child view
+func (v *RemoteView) peek(owner, key string) (flow.RegisterValue, error) {
 
 	// first check the delta
 	value, found := v.Delta[owner+"~"+key]
@@ -62,7 +91,7 @@ func (v *RemoteView) Get(owner, key string) (flow.RegisterValue, error) {
 
 	// then call the parent (if exist)
 	if v.Parent != nil {
-		return v.Parent.Get(owner, key)
+		return v.Parent.peek(owner, key)
 	}
 
 	// last use the getRemoteRegister
@@ -74,21 +103,46 @@ func (v *RemoteView) Get(owner, key string) (flow.RegisterValue, error) {
 	return resp, nil
 }
 
-// returns all the registers that has been touched
+// AllRegisters returns all the registers that have been touched
 func (v *RemoteView) AllRegisters() []flow.RegisterID {
-	panic("Not implemented yet")
+	ids := make([]flow.RegisterID, 0, len(v.touched))
+	for _, id := range v.touched {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Owner != ids[j].Owner {
+			return ids[i].Owner < ids[j].Owner
+		}
+		return ids[i].Key < ids[j].Key
+	})
+	return ids
 }
 
+// RegisterUpdates returns all the registers that have been written to, sorted by register ID
 func (v *RemoteView) RegisterUpdates() ([]flow.RegisterID, []flow.RegisterValue) {
-	panic("Not implemented yet")
+	entries := make(flow.RegisterEntries, 0, len(v.Delta))
+	for k, value := range v.Delta {
+		entries = append(entries, flow.RegisterEntry{
+			Key:   v.touched[k],
+			Value: value,
+		})
+	}
+	sort.Sort(&entries)
+
+	return entries.IDs(), entries.Values()
 }
 
 func (v *RemoteView) Touch(owner, key string) error {
-	// no-op for now
+	v.touch(owner, key)
 	return nil
 }
 
 func (v *RemoteView) Delete(owner, key string) error {
+	v.touch(owner, key)
 	v.Delta[owner+"~"+key] = nil
 	return nil
 }
+
+func (v *RemoteView) touch(owner, key string) {
+	v.touched[owner+"~"+key] = flow.NewRegisterID(owner, key)
+}
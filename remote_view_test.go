@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onflow/flow-go/model/flow"
+)
+
+func TestRemoteViewRegisters(t *testing.T) {
+	remote := map[string]flow.RegisterValue{
+		"b~read": {9},
+		"c~gone": {8},
+	}
+	get := func(owner, key string) (flow.RegisterValue, error) {
+		return remote[owner+"~"+key], nil
+	}
+
+	view := NewRemoteView(get)
+	if err := view.Set("b", "x", flow.RegisterValue{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := view.Set("a", "y", flow.RegisterValue{2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := view.Delete("c", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := view.Get("b", "read"); err != nil {
+		t.Fatal(err)
+	}
+	child := view.NewChild()
+	if err := child.Set("a", "child", flow.RegisterValue{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := view.MergeView(child); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedUpdates := []flow.RegisterEntry{
+		{Key: flow.NewRegisterID("a", "child"), Value: flow.RegisterValue{3}},
+		{Key: flow.NewRegisterID("a", "y"), Value: flow.RegisterValue{2}},
+		{Key: flow.NewRegisterID("b", "x"), Value: flow.RegisterValue{1}},
+		{Key: flow.NewRegisterID("c", "gone"), Value: nil},
+	}
+	expectedAll := []flow.RegisterID{
+		flow.NewRegisterID("a", "child"),
+		flow.NewRegisterID("a", "y"),
+		flow.NewRegisterID("b", "read"),
+		flow.NewRegisterID("b", "x"),
+		flow.NewRegisterID("c", "gone"),
+	}
+
+	views := map[string]*RemoteView{
+		"view": view,
+		"copy": view.CopyWithSource(get),
+	}
+	for name, v := range views {
+		ids, values := v.RegisterUpdates()
+		if len(ids) != len(expectedUpdates) || len(values) != len(expectedUpdates) {
+			t.Fatalf("%s: expected %d updates, got %d ids and %d values", name, len(expectedUpdates), len(ids), len(values))
+		}
+		for i, expected := range expectedUpdates {
+			if ids[i] != expected.Key {
+				t.Fatalf("%s: update %d is %s, expected %s", name, i, ids[i], expected.Key)
+			}
+			if !bytes.Equal(values[i], expected.Value) || (values[i] == nil) != (expected.Value == nil) {
+				t.Fatalf("%s: update %d value is %x, expected %x", name, i, values[i], expected.Value)
+			}
+		}
+
+		all := v.AllRegisters()
+		if len(all) != len(expectedAll) {
+			t.Fatalf("%s: expected %d registers, got %d", name, len(expectedAll), len(all))
+		}
+		for i, expected := range expectedAll {
+			if all[i] != expected {
+				t.Fatalf("%s: register %d is %s, expected %s", name, i, all[i], expected)
+			}
+		}
+	}
+
+	// the copy reads the registers that are not in the delta from its own source
+	value, err := views["copy"].Get("c", "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != nil {
+		t.Fatalf("deleted register read as %x", value)
+	}
+}
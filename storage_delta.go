@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/csv"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/flow-go/fvm/environment"
+	"github.com/onflow/flow-go/fvm/state"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+type storageDeltaEntry struct {
+	address           flow.Address
+	storageUsedBefore uint64
+	storageUsedAfter  uint64
+	storageCapacity   uint64
+}
+
+// exceedsCapacity is true if the account would fail the storage check at the end of the transaction.
+func (e storageDeltaEntry) exceedsCapacity() bool {
+	return e.storageUsedAfter > e.storageCapacity
+}
+
+func (e storageDeltaEntry) delta() int64 {
+	return int64(e.storageUsedAfter) - int64(e.storageUsedBefore)
+}
+
+// StorageDeltaReport reports the storage used before and after the transaction
+// by every account the transaction wrote to, and the storage capacity of the accounts.
+type StorageDeltaReport struct {
+	entries []storageDeltaEntry
+
+	log      zerolog.Logger
+	filename string
+}
+
+// TouchedAccounts returns the accounts of the written registers, sorted.
+func TouchedAccounts(ids []flow.RegisterID) []flow.Address {
+	touched := make(map[flow.Address]struct{})
+	for _, id := range ids {
+		if id.Owner == "" {
+			continue
+		}
+		touched[flow.BytesToAddress([]byte(id.Owner))] = struct{}{}
+	}
+	addresses := make([]flow.Address, 0, len(touched))
+	for address := range touched {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+	return addresses
+}
+
+// NewStorageDeltaReport reads the storage used of the accounts from their account status registers
+// before the transaction with getBefore and after the transaction with getAfter.
+// capacities are the storage capacities of the accounts after the transaction.
+func NewStorageDeltaReport(
+	addresses []flow.Address,
+	capacities []uint64,
+	getBefore registers.RegisterGetRegisterFunc,
+	getAfter registers.RegisterGetRegisterFunc,
+	directory string,
+	log zerolog.Logger,
+) (*StorageDeltaReport, error) {
+	r := &StorageDeltaReport{
+		entries:  make([]storageDeltaEntry, 0, len(addresses)),
+		log:      log,
+		filename: directory + "/storage_delta.csv",
+	}
+	for i, address := range addresses {
+		before, err := storageUsed(getBefore, address)
+		if err != nil {
+			return nil, err
+		}
+		after, err := storageUsed(getAfter, address)
+		if err != nil {
+			return nil, err
+		}
+		r.entries = append(r.entries, storageDeltaEntry{
+			address:           address,
+			storageUsedBefore: before,
+			storageUsedAfter:  after,
+			storageCapacity:   capacities[i],
+		})
+	}
+	return r, nil
+}
+
+// storageUsed reads the storage used of the account from its account status register.
+// Accounts that do not exist use no storage.
+func storageUsed(get registers.RegisterGetRegisterFunc, address flow.Address) (uint64, error) {
+	value, err := get(string(address.Bytes()), state.KeyAccountStatus)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 {
+		return 0, nil
+	}
+	status, err := environment.AccountStatusFromBytes(value)
+	if err != nil {
+		return 0, err
+	}
+	return status.StorageUsed(), nil
+}
+
+func (r *StorageDeltaReport) Close() error {
+	var totalDelta int64
+	for _, entry := range r.entries {
+		totalDelta += entry.delta()
+		if entry.exceedsCapacity() {
+			r.log.Warn().
+				Str("address", entry.address.HexWithPrefix()).
+				Uint64("storageUsed", entry.storageUsedAfter).
+				Uint64("storageCapacity", entry.storageCapacity).
+				Msg("Account storage used exceeds its storage capacity.")
+		}
+	}
+	r.log.Info().
+		Int("accounts", len(r.entries)).
+		Int64("storageUsedDelta", totalDelta).
+		Msg("Storage used delta.")
+
+	err := os.MkdirAll(filepath.Dir(r.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	csvFile, err := os.Create(r.filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := csvFile.Close()
+		if err != nil {
+			r.log.Warn().
+				Err(err).
+				Msg("Could not close csv file.")
+		}
+	}()
+
+	writer := csv.NewWriter(csvFile)
+	defer writer.Flush()
+	err = writer.Write([]string{"Address", "Storage used before", "Storage used after", "Delta", "Storage capacity", "Exceeds capacity"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range r.entries {
+		err := writer.Write([]string{
+			entry.address.HexWithPrefix(),
+			strconv.FormatUint(entry.storageUsedBefore, 10),
+			strconv.FormatUint(entry.storageUsedAfter, 10),
+			strconv.FormatInt(entry.delta(), 10),
+			strconv.FormatUint(entry.storageCapacity, 10),
+			strconv.FormatBool(entry.exceedsCapacity()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
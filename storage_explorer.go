@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/janezpodhostnik/flow-transaction-info/backends"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"github.com/onflow/atree"
+	"github.com/onflow/cadence/runtime"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/cadence/runtime/interpreter"
+	"github.com/onflow/flow-go/fvm/environment"
+	"github.com/onflow/flow-go/fvm/state"
+	"github.com/onflow/flow-go/model/flow"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"sort"
+)
+
+// storageExplorerDomains are the Cadence storage domains of an account, in the order they are shown.
+var storageExplorerDomains = []string{
+	common.PathDomainStorage.Identifier(),
+	common.PathDomainPublic.Identifier(),
+	common.PathDomainPrivate.Identifier(),
+	runtime.StorageDomainContract,
+}
+
+// StorageExplorer prints the tree of the values stored in an account,
+// with their Cadence types and the size of the slabs they are stored in.
+// The account storage is read as it was before the block at blockHeight was executed.
+type StorageExplorer struct {
+	address     flow.Address
+	blockHeight uint64
+
+	// source returns the source the registers at a block height are read from
+	source func(ctx context.Context, blockHeight uint64) *registers.BatchingRegisterSource
+	// cache returns the cache of the registers at a block height
+	cache func(blockHeight uint64) (*registers.RemoteRegisterFileCache, error)
+
+	out io.Writer
+	log zerolog.Logger
+}
+
+func NewStorageExplorer(
+	address flow.Address,
+	blockHeight uint64,
+	backend backends.Backend,
+	chain flow.Chain,
+	logger zerolog.Logger,
+	options ...TransactionDebuggerOption) *StorageExplorer {
+
+	debugger := NewTransactionDebugger(flow.ZeroID, backend, chain, logger, options...)
+	return &StorageExplorer{
+		address:     address,
+		blockHeight: blockHeight,
+		source:      debugger.registerSource,
+		cache:       debugger.registerCache,
+		out:         os.Stdout,
+		log:         logger,
+	}
+}
+
+func (e *StorageExplorer) Run(ctx context.Context) error {
+	source := e.source(ctx, e.blockHeight)
+	defer func() {
+		err := source.Close()
+		if err != nil {
+			e.log.Warn().
+				Err(err).
+				Msg("Could not close register source.")
+		}
+	}()
+
+	cache, err := e.cache(e.blockHeight)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := cache.Close()
+		if err != nil {
+			e.log.Warn().
+				Err(err).
+				Msg("Could not close register cache.")
+		}
+	}()
+
+	return e.printStorage(cache.Wrap(source.Get))
+}
+
+// printStorage prints the storage tree of the account, reading registers with get.
+func (e *StorageExplorer) printStorage(get registers.RegisterGetRegisterFunc) error {
+	owner := string(e.address.Bytes())
+	status, err := get(owner, state.KeyAccountStatus)
+	if err != nil {
+		return err
+	}
+	if len(status) == 0 {
+		return fmt.Errorf("account %s does not exist at block %d", e.address.HexWithPrefix(), e.blockHeight)
+	}
+	accountStatus, err := environment.AccountStatusFromBytes(status)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(e.out, "%s before block %d, storage used: %d bytes\n",
+		e.address.HexWithPrefix(), e.blockHeight, accountStatus.StorageUsed())
+
+	storage := runtime.NewStorage(registerLedger(get), nil)
+	inter, err := interpreter.NewInterpreter(nil, common.AddressLocation{}, &interpreter.Config{
+		Storage: storage,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range storageExplorerDomains {
+		err := e.printDomain(inter, storage, get, domain)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printDomain prints the domain and every value stored in it.
+func (e *StorageExplorer) printDomain(
+	inter *interpreter.Interpreter,
+	storage *runtime.Storage,
+	get registers.RegisterGetRegisterFunc,
+	domain string,
+) (err error) {
+	// cadence storage panics on errors reading registers
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("could not read %s domain: %v", domain, r)
+		}
+	}()
+
+	storageMap := storage.GetStorageMap(common.Address(e.address), domain, false)
+	if storageMap == nil {
+		_, _ = fmt.Fprintf(e.out, "%s: empty\n", domain)
+		return nil
+	}
+	size, err := slabsSize(get, storageMap.StorageID())
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(e.out, "%s: %d values, %s\n", domain, storageMap.Count(), size)
+
+	// print the values sorted by key, the storage map is ordered by hash
+	lines := make(map[string]string, storageMap.Count())
+	iterator := storageMap.Iterator(nil)
+	for key, value := iterator.Next(); value != nil; key, value = iterator.Next() {
+		staticType := value.StaticType(inter)
+		typeName := "unknown type"
+		if staticType != nil {
+			typeName = staticType.String()
+		}
+
+		container, ok := value.(interface{ StorageID() atree.StorageID })
+		if !ok {
+			// values that are not containers are stored inline in the domain slabs
+			lines[key] = fmt.Sprintf("%s, inline: %s", typeName, value.String())
+			continue
+		}
+		size, err := slabsSize(get, container.StorageID())
+		if err != nil {
+			return err
+		}
+		lines[key] = fmt.Sprintf("%s, %s", typeName, size)
+	}
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(e.out, "  %s: %s\n", key, lines[key])
+	}
+	return nil
+}
+
+type slabSize struct {
+	slabs int
+	bytes int
+}
+
+func (s slabSize) String() string {
+	return fmt.Sprintf("%d slabs, %d bytes", s.slabs, s.bytes)
+}
+
+// slabsSize returns the number and size of the slab with the storage ID
+// and all the slabs it references, which includes the slabs of nested values.
+func slabsSize(get registers.RegisterGetRegisterFunc, id atree.StorageID) (slabSize, error) {
+	key := registers.SlabRegisterKey(id)
+	value, err := get(key.Owner, key.Key)
+	if err != nil {
+		return slabSize{}, err
+	}
+	slab, err := registers.DecodeSlab(key, value)
+	if err != nil {
+		return slabSize{}, fmt.Errorf("could not decode slab %s: %w", key, err)
+	}
+
+	size := slabSize{
+		slabs: 1,
+		bytes: len(value),
+	}
+	for _, storable := range slab.ChildStorables() {
+		child, ok := storable.(atree.StorageIDStorable)
+		if !ok {
+			continue
+		}
+		childSize, err := slabsSize(get, atree.StorageID(child))
+		if err != nil {
+			return slabSize{}, err
+		}
+		size.slabs += childSize.slabs
+		size.bytes += childSize.bytes
+	}
+	return size, nil
+}
+
+// registerLedger is a read only atree ledger of the registers read with the register func.
+type registerLedger registers.RegisterGetRegisterFunc
+
+var _ atree.Ledger = registerLedger(nil)
+
+func (l registerLedger) GetValue(owner, key []byte) ([]byte, error) {
+	return l(string(owner), string(key))
+}
+
+func (l registerLedger) SetValue(_, _, _ []byte) error {
+	return fmt.Errorf("storage explorer is read only")
+}
+
+func (l registerLedger) ValueExists(owner, key []byte) (bool, error) {
+	value, err := l(string(owner), string(key))
+	return len(value) > 0, err
+}
+
+func (l registerLedger) AllocateStorageIndex(_ []byte) (atree.StorageIndex, error) {
+	return atree.StorageIndex{}, fmt.Errorf("storage explorer is read only")
+}
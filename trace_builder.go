@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/google/pprof/profile"
+	"github.com/janezpodhostnik/flow-transaction-info/registers"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type traceEvent struct {
+	Name     string `json:"name"`
+	Category string `json:"cat"`
+	Phase    string `json:"ph"`
+	Scope    string `json:"s,omitempty"`
+	// Timestamp is the computation used so far, shown as microseconds
+	Timestamp uint64            `json:"ts"`
+	ProcessID int               `json:"pid"`
+	ThreadID  int               `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// traceFrame identifies a call frame by the function and the location it was called from.
+// Consecutive calls of the same function from the same location are merged into one span.
+type traceFrame struct {
+	function *profile.Function
+	caller   *profile.Location
+}
+
+// TraceBuilder builds a Chrome trace of the Cadence execution,
+// with a span per call frame and an instant event per register read.
+// Time is measured in computation used.
+type TraceBuilder struct {
+	events []traceEvent
+	stack  []traceFrame
+
+	computation uint64
+	filename    string
+}
+
+func NewTraceBuilder(directory string) *TraceBuilder {
+	return &TraceBuilder{
+		events:   []traceEvent{},
+		filename: directory + "/trace.json",
+	}
+}
+
+// OnStatement ends the spans of the frames that returned and begins the spans of the new frames.
+// locations are the sample locations, leaf first.
+func (t *TraceBuilder) OnStatement(locations []*profile.Location, computation uint64) {
+	t.computation = computation
+
+	stack := make([]traceFrame, 0, len(locations))
+	var caller *profile.Location
+	for i := len(locations) - 1; i >= 0; i-- {
+		stack = append(stack, traceFrame{
+			function: locationFunction(locations[i]),
+			caller:   caller,
+		})
+		caller = locations[i]
+	}
+
+	common := 0
+	for common < len(stack) && common < len(t.stack) && stack[common] == t.stack[common] {
+		common++
+	}
+	t.endFrames(common)
+	for _, frame := range stack[common:] {
+		t.events = append(t.events, t.frameEvent(frame, "B"))
+	}
+	t.stack = stack
+}
+
+// OnRegisterRead adds an instant event for the register read.
+func (t *TraceBuilder) OnRegisterRead(key registers.RegisterKey, read int) {
+	t.events = append(t.events, traceEvent{
+		Name:      "register read",
+		Category:  "register",
+		Phase:     "i",
+		Scope:     "t",
+		Timestamp: t.computation,
+		ProcessID: 1,
+		ThreadID:  1,
+		Args: map[string]string{
+			"owner": key.Owner,
+			"key":   key.Key,
+			"bytes": strconv.Itoa(read),
+		},
+	})
+}
+
+// endFrames ends the spans of the frames above the given depth.
+func (t *TraceBuilder) endFrames(depth int) {
+	for i := len(t.stack) - 1; i >= depth; i-- {
+		t.events = append(t.events, t.frameEvent(t.stack[i], "E"))
+	}
+	t.stack = t.stack[:depth]
+}
+
+func (t *TraceBuilder) frameEvent(frame traceFrame, phase string) traceEvent {
+	event := traceEvent{
+		Name:      frame.function.Name,
+		Category:  "cadence",
+		Phase:     phase,
+		Timestamp: t.computation,
+		ProcessID: 1,
+		ThreadID:  1,
+	}
+	if phase == "B" {
+		event.Args = map[string]string{
+			"file": frame.function.Filename,
+		}
+	}
+	return event
+}
+
+// Close ends the spans of all the frames and writes the trace.
+func (t *TraceBuilder) Close(computation uint64) error {
+	t.computation = computation
+	t.endFrames(0)
+
+	err := os.MkdirAll(filepath.Dir(t.filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(traceFile{
+		TraceEvents:     t.events,
+		DisplayTimeUnit: "ms",
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.filename, data, 0644)
+}
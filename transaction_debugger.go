@@ -4,53 +4,133 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"github.com/janezpodhostnik/flow-transaction-info/backends"
 	"github.com/janezpodhostnik/flow-transaction-info/registers"
-	"github.com/onflow/flow-dps/api/dps"
-	"github.com/onflow/flow-dps/codec/zbor"
-	"github.com/onflow/flow-go/engine/execution/state"
-	"github.com/onflow/flow-go/ledger/common/pathfinder"
-	"github.com/onflow/flow-go/ledger/complete"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go/fvm"
+	"github.com/onflow/flow-go/fvm/meter"
 	"github.com/onflow/flow-go/model/flow"
 	"github.com/rs/zerolog"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type TransactionDebugger struct {
-	txID        flow.Identifier
-	archiveHost string
-	chain       flow.Chain
+	txID    flow.Identifier
+	backend backends.Backend
+	chain   flow.Chain
 
 	directory string
 
+	// verify the replayed register writes against the network execution
+	verify bool
+	// maximum number of registers requested from the backend in one round-trip
+	batchSize int
+	// register traces of previous runs used to prefetch registers
+	prefetchTraces []string
+	// file to export the offline replay bundle to
+	exportFile string
+	// run the sequence number check, signature verification and fee deduction as well
+	fullPipeline bool
+	// formats the execution profile is written in
+	profileFormats []ProfileFormat
+	// compare the emitted events to the events the network recorded
+	compareEvents bool
+	// print the Cadence logs to the console as they are logged
+	liveLogs bool
+
 	log zerolog.Logger
 }
 
+type TransactionDebuggerOption func(*TransactionDebugger)
+
+// WithVerification makes the debugger compare the replayed register writes
+// to the register values after the transaction block was executed on the network.
+func WithVerification() TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.verify = true
+	}
+}
+
+// WithBatchSize sets the maximum number of registers requested from the backend in one round-trip.
+func WithBatchSize(batchSize int) TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.batchSize = batchSize
+	}
+}
+
+// WithPrefetch makes the debugger load the registers listed in the register traces
+// (e.g. registers_read.csv of a previous run) into the cache before running the transaction.
+func WithPrefetch(traces ...string) TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.prefetchTraces = append(d.prefetchTraces, traces...)
+	}
+}
+
+// WithExport makes the debugger write an offline replay bundle to filename after running the transaction.
+func WithExport(filename string) TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.exportFile = filename
+	}
+}
+
+// WithFullPipeline makes the debugger run the sequence number check, signature verification
+// and fee deduction together with the transaction, and report the fees charged.
+func WithFullPipeline() TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.fullPipeline = true
+	}
+}
+
+// WithEventComparison makes the debugger compare the events the transaction emitted
+// to the events the network recorded for the transaction.
+func WithEventComparison() TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.compareEvents = true
+	}
+}
+
+// WithLiveLogs makes the debugger print the messages the transaction logs with the Cadence log function
+// as they are logged, they are always written to cadence_logs.txt.
+func WithLiveLogs() TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.liveLogs = true
+	}
+}
+
+// WithProfileFormats sets the formats the execution profile is written in.
+func WithProfileFormats(formats ...ProfileFormat) TransactionDebuggerOption {
+	return func(d *TransactionDebugger) {
+		d.profileFormats = formats
+	}
+}
+
 func NewTransactionDebugger(
 	txID flow.Identifier,
-	archiveHost string,
+	backend backends.Backend,
 	chain flow.Chain,
-	logger zerolog.Logger) *TransactionDebugger {
+	logger zerolog.Logger,
+	options ...TransactionDebuggerOption) *TransactionDebugger {
 
-	return &TransactionDebugger{
-		txID:        txID,
-		archiveHost: archiveHost,
-		chain:       chain,
+	d := &TransactionDebugger{
+		txID:    txID,
+		backend: backend,
+		chain:   chain,
 
-		directory: "t_" + txID.String(),
+		directory:      "t_" + txID.String(),
+		batchSize:      registers.DefaultBatchSize,
+		profileFormats: DefaultProfileFormats,
 
 		log: logger,
 	}
-}
-
-type clientWithConnection struct {
-	dps.APIClient
-	*grpc.ClientConn
+	for _, option := range options {
+		option(d)
+	}
+	return d
 }
 
 func (d *TransactionDebugger) RunTransaction(ctx context.Context) (txErr, processError error) {
@@ -58,49 +138,104 @@ func (d *TransactionDebugger) RunTransaction(ctx context.Context) (txErr, proces
 		Str("txID", d.txID.String()).
 		Msg("Running transaction. This may differ from how the transaction was actually run on the network.")
 
-	client, err := d.getClient()
+	blockHeight, err := d.getTransactionBlockHeight(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	header, err := d.backend.GetBlockHeader(ctx, blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	err = d.dumpBlockHeaderToFile(header)
+	if err != nil {
+		return nil, err
+	}
+
+	source := d.registerSource(ctx, blockHeight)
 	defer func() {
-		err := client.Close()
+		err := source.Close()
 		if err != nil {
 			d.log.Warn().
 				Err(err).
-				Msg("Could not close client connection.")
+				Msg("Could not close register source.")
 		}
 	}()
 
-	blockHeight, err := d.getTransactionBlockHeight(ctx, client)
+	cache, err := d.registerCache(blockHeight)
 	if err != nil {
 		return nil, err
 	}
-
-	readFunc := func(address string, key string) (flow.RegisterValue, error) {
-		ledgerKey := state.RegisterIDToKey(flow.RegisterID{Key: key, Owner: address})
-		ledgerPath, err := pathfinder.KeyToPath(ledgerKey, complete.DefaultPathFinderVersion)
+	defer func() {
+		err := cache.Close()
 		if err != nil {
-			return nil, err
+			d.log.Warn().
+				Err(err).
+				Msg("Could not close register cache.")
 		}
+	}()
+	err = d.prefetch(cache, source)
+	if err != nil {
+		return nil, err
+	}
 
-		resp, err := client.GetRegisterValues(ctx, &dps.GetRegisterValuesRequest{
-			Height: blockHeight,
-			Paths:  [][]byte{ledgerPath[:]},
-		})
-		if err != nil {
-			return nil, err
-		}
-		return resp.Values[0], nil
+	// reads the value a register had before the transaction, without tracking the read
+	previousValueFunc := cache.Wrap(source.Get)
+
+	txBody, err := d.backend.GetTransaction(ctx, d.txID)
+	if err != nil {
+		return nil, err
 	}
 
-	cache, err := registers.NewRemoteRegisterFileCache(blockHeight, d.log)
+	replay, err := d.replayTransaction(ctx, header, txBody, 0, previousValueFunc)
 	if err != nil {
 		return nil, err
 	}
+	txErr = replay.tx.Err
+
+	if d.exportFile != "" {
+		err = d.exportBundle(header, txBody, cache, replay.contracts)
+		if err != nil {
+			return txErr, err
+		}
+	}
+	if d.verify {
+		executedSource := d.registerSource(ctx, blockHeight+1)
+		err = d.verifyRegisterWrites(replay.view, previousValueFunc, executedSource.GetMany)
+		if closeErr := executedSource.Close(); closeErr != nil {
+			d.log.Warn().
+				Err(closeErr).
+				Msg("Could not close register source.")
+		}
+	}
+
+	return txErr, err
+}
+
+type transactionReplay struct {
+	view      *RemoteView
+	tx        *fvm.TransactionProcedure
+	contracts map[string]map[string]string
+}
+
+// replayTransaction runs the transaction on a view reading registers with readFunc,
+// and writes the transaction output to the debugger directory.
+func (d *TransactionDebugger) replayTransaction(
+	ctx context.Context,
+	header *flow.Header,
+	txBody *flow.TransactionBody,
+	txIndex uint32,
+	readFunc registers.RegisterGetRegisterFunc,
+) (*transactionReplay, error) {
+	// readFunc reads the value a register had before the transaction, without tracking the read
+	previousValueFunc := readFunc
+
+	contractCapture := registers.NewCaptureContractWrapper(d.directory, d.log)
+	readTracker := registers.NewRemoteRegisterReadTracker(d.directory, d.log)
 	registerReadWrapper := []registers.RegisterGetWrapper{
-		cache,
-		registers.NewRemoteRegisterReadTracker(d.directory, d.log),
-		registers.NewCaptureContractWrapper(d.directory, d.log),
+		readTracker,
+		contractCapture,
+		registers.NewRegisterDecoder(d.directory, d.log),
 	}
 
 	for _, wrapper := range registerReadWrapper {
@@ -119,7 +254,7 @@ func (d *TransactionDebugger) RunTransaction(ctx context.Context) (txErr, proces
 		}
 	}()
 
-	debugger := NewRemoteDebugger(view, d.chain, d.directory, d.log.Output(logInterceptor))
+	debugger := NewRemoteDebugger(view, d.chain, header, d.directory, d.fullPipeline, d.profileFormats, d.log.Output(logInterceptor))
 	defer func(debugger *RemoteDebugger) {
 		err := debugger.Close()
 		if err != nil {
@@ -128,30 +263,37 @@ func (d *TransactionDebugger) RunTransaction(ctx context.Context) (txErr, proces
 				Msg("Could not close debugger.")
 		}
 	}(debugger)
+	readTracker.AddListener(debugger.ProfileBuilder().OnRegisterRead)
+	readTracker.SetCallerFunc(debugger.ProfileBuilder().Caller)
+	cadenceLogs := NewCadenceLogs(d.directory, d.liveLogs, d.log)
+	debugger.ProfileBuilder().AddLogListener(cadenceLogs.OnLog)
 
-	codec := zbor.NewCodec()
-
-	txResult, err := client.GetTransaction(ctx, &dps.GetTransactionRequest{
-		TransactionID: d.txID[:],
-	})
-	if err != nil {
-		d.log.Error().
-			Err(err).
-			Msg("Could not get transaction.")
-		return
-	}
-	var txBody flow.TransactionBody
-	err = codec.Unmarshal(txResult.Data, &txBody)
+	err := d.dumpTransactionToFile(*txBody)
 	if err != nil {
-		d.log.Error().
-			Err(err).
-			Msg("Could not unmarshal transaction.")
-		return
+		return nil, err
 	}
 
-	err = d.dumpTransactionToFile(txBody)
-
-	txErr, err = debugger.RunTransaction(&txBody)
+	tx, err := debugger.RunTransaction(txBody, txIndex)
+	if err == nil {
+		debugger.ProfileBuilder().FlushLogs()
+		err = cadenceLogs.Close()
+	}
+	if err == nil {
+		d.logProfile(debugger.ProfileBuilder())
+		err = d.trackRegisterWrites(view, previousValueFunc)
+	}
+	if err == nil {
+		err = d.setExecutionParameters(debugger, logInterceptor, previousValueFunc)
+	}
+	if err == nil {
+		err = d.reportStorageDelta(debugger, view, previousValueFunc)
+	}
+	if err == nil {
+		err = d.reportEvents(ctx, txBody.ID(), tx.Events)
+	}
+	if err == nil && d.fullPipeline {
+		err = d.reportFees(debugger, view, previousValueFunc, tx)
+	}
 
 	for _, wrapper := range registerReadWrapper {
 		switch w := wrapper.(type) {
@@ -164,42 +306,238 @@ func (d *TransactionDebugger) RunTransaction(ctx context.Context) (txErr, proces
 			}
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return txErr, err
+	return &transactionReplay{
+		view:      view,
+		tx:        tx,
+		contracts: contractCapture.Contracts(),
+	}, nil
+}
+
+func (d *TransactionDebugger) logProfile(profileBuilder *ProfileBuilder) {
+	logEvent := d.log.Info()
+	if profileBuilder.UnclassifiedFrames > 0 {
+		logEvent = d.log.Warn()
+	}
+	logEvent.
+		Int("samples", len(profileBuilder.Profile.Sample)).
+		Int("functions", len(profileBuilder.Profile.Function)).
+		Int("unclassifiedFrames", profileBuilder.UnclassifiedFrames).
+		Msg("Built execution profile.")
+}
+
+// setExecutionParameters reads the execution parameters of the network from the state before the transaction.
+func (d *TransactionDebugger) setExecutionParameters(
+	debugger *RemoteDebugger,
+	logInterceptor *LogInterceptor,
+	previousValueFunc registers.RegisterGetRegisterFunc,
+) error {
+	params, err := debugger.GetExecutionParameters(NewRemoteView(previousValueFunc))
+	if err != nil {
+		return err
+	}
+	logInterceptor.SetExecutionParameters(params)
+	return nil
+}
+
+func (d *TransactionDebugger) trackRegisterWrites(view *RemoteView, previousValueFunc registers.RegisterGetRegisterFunc) error {
+	writeTracker := registers.NewRemoteRegisterWriteTracker(d.directory, d.log)
+
+	ids, values := view.RegisterUpdates()
+	err := writeTracker.Track(ids, values, previousValueFunc)
+	if err != nil {
+		return err
+	}
+
+	d.log.Info().
+		Int("registers", len(ids)).
+		Msg("Tracked register writes.")
+	return writeTracker.Close()
 }
 
-func (d *TransactionDebugger) getClient() (clientWithConnection, error) {
-	conn, err := grpc.Dial(
-		d.archiveHost,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+// reportEvents writes the events the transaction emitted,
+// compared to the events the network recorded if event comparison is enabled
+func (d *TransactionDebugger) reportEvents(ctx context.Context, txID flow.Identifier, events []flow.Event) error {
+	report, err := NewEventsReport(events, d.directory, d.log)
+	if err != nil {
+		return err
+	}
+	if d.compareEvents {
+		networkEvents, err := d.backend.GetTransactionEvents(ctx, txID)
+		if err != nil {
+			return err
+		}
+		if !d.fullPipeline {
+			// the replay does not deduct fees, so the fee deduction events are not compared
+			networkEvents = WithoutFeeDeductionEvents(networkEvents)
+		}
+		err = report.Compare(networkEvents)
+		if err != nil {
+			return err
+		}
+	}
+	return report.Close()
+}
+
+// reportStorageDelta writes the storage used before and after the transaction
+// and the storage capacity of every account the transaction wrote to
+func (d *TransactionDebugger) reportStorageDelta(
+	debugger *RemoteDebugger,
+	view *RemoteView,
+	previousValueFunc registers.RegisterGetRegisterFunc,
+) error {
+	ids, _ := view.RegisterUpdates()
+	addresses := TouchedAccounts(ids)
+
+	// the state after the transaction is read from copies of the view, so that the reads are not tracked
+	capacities, err := debugger.GetStorageCapacities(view.CopyWithSource(previousValueFunc), addresses)
+	if err != nil {
+		return err
+	}
+
+	after := view.CopyWithSource(previousValueFunc)
+	report, err := NewStorageDeltaReport(addresses, capacities, previousValueFunc, after.Get, d.directory, d.log)
+	if err != nil {
+		return err
+	}
+	return report.Close()
+}
+
+// reportFees writes the fees charged, the payer balance before and after the transaction
+// and the computation used by each processing step
+func (d *TransactionDebugger) reportFees(
+	debugger *RemoteDebugger,
+	view *RemoteView,
+	previousValueFunc registers.RegisterGetRegisterFunc,
+	tx *fvm.TransactionProcedure,
+) error {
+	payer := tx.Transaction.Payer
+
+	// the balances are read from copies of the view, so that the reads are not tracked
+	balanceBefore, err := debugger.GetAccountBalance(NewRemoteView(previousValueFunc), payer)
+	if err != nil {
+		return err
+	}
+	balanceAfter, err := debugger.GetAccountBalance(view.CopyWithSource(previousValueFunc), payer)
+	if err != nil {
+		return err
+	}
+
+	report, err := NewFeeReport(
+		payer,
+		balanceBefore,
+		balanceAfter,
+		tx.Events,
+		debugger.PipelineSteps(),
+		d.directory,
+		d.log,
 	)
+	if err != nil {
+		return err
+	}
+	return report.Close()
+}
+
+// exportBundle writes everything needed to replay the transaction offline to the export file
+func (d *TransactionDebugger) exportBundle(
+	header *flow.Header,
+	txBody *flow.TransactionBody,
+	cache *registers.RemoteRegisterFileCache,
+	contracts map[string]map[string]string,
+) error {
+	bundle := &backends.Bundle{
+		ChainID:       d.chain.ChainID(),
+		TransactionID: d.txID,
+		BlockHeight:   header.Height,
+		BlockHeader:   (*backends.BlockHeader)(header),
+		Transaction:   txBody,
+		Contracts:     contracts,
+	}
+	bundle.SetRegisters(cache.UsedRegisters())
+
+	err := backends.WriteBundle(d.exportFile, bundle)
 	if err != nil {
 		d.log.Error().
 			Err(err).
-			Str("host", d.archiveHost).
-			Msg("Could not connect to server.")
-		return clientWithConnection{}, err
+			Str("file", d.exportFile).
+			Msg("Could not write bundle.")
+		return err
 	}
-	client := dps.NewAPIClient(conn)
 
-	return clientWithConnection{
-		APIClient:  client,
-		ClientConn: conn,
-	}, nil
+	d.log.Info().
+		Int("registers", len(bundle.Registers)).
+		Str("file", d.exportFile).
+		Msg("Exported offline replay bundle.")
+	return nil
+}
+
+// prefetch loads the registers from the prefetch traces into the cache
+func (d *TransactionDebugger) prefetch(
+	cache *registers.RemoteRegisterFileCache,
+	source *registers.BatchingRegisterSource,
+) error {
+	for _, trace := range d.prefetchTraces {
+		keys, err := registers.LoadRegisterTrace(trace)
+		if err != nil {
+			d.log.Error().
+				Err(err).
+				Str("trace", trace).
+				Msg("Could not load register trace.")
+			return err
+		}
+		err = cache.Prefetch(keys, source.GetMany)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (d *TransactionDebugger) getTransactionBlockHeight(ctx context.Context, client dps.APIClient) (uint64, error) {
+// verifyRegisterWrites compares the register writes of the replay to the register values read with executedValuesFunc.
+func (d *TransactionDebugger) verifyRegisterWrites(
+	view *RemoteView,
+	previousValueFunc registers.RegisterGetRegisterFunc,
+	executedValuesFunc registers.RegisterBatchGetFunc,
+) error {
+	verifier := registers.NewRegisterWriteVerifier(d.directory, d.log)
 
-	resp, err := client.GetHeightForTransaction(ctx, &dps.GetHeightForTransactionRequest{
-		TransactionID: d.txID[:],
-	})
+	ids, values := view.RegisterUpdates()
+	err := verifier.Verify(ids, values, previousValueFunc, executedValuesFunc)
+	if err != nil {
+		return err
+	}
+	return verifier.Close()
+}
+
+// registerSource returns a register source reading registers at blockHeight, batching the reads.
+func (d *TransactionDebugger) registerSource(
+	ctx context.Context,
+	blockHeight uint64,
+) *registers.BatchingRegisterSource {
+	batchGet := func(keys []registers.RegisterKey) ([]flow.RegisterValue, error) {
+		return d.backend.GetRegisterValues(ctx, blockHeight, keys)
+	}
+
+	return registers.NewBatchingRegisterSource(batchGet, d.batchSize, registers.DefaultParallelism, d.log)
+}
+
+// registerCache returns the cache of the registers at blockHeight.
+// Replaying offline, all registers come from the bundle, so the cache file is not used.
+func (d *TransactionDebugger) registerCache(blockHeight uint64) (*registers.RemoteRegisterFileCache, error) {
+	if _, offline := d.backend.(*backends.OfflineBackend); offline {
+		return registers.NewRemoteRegisterMemoryCache(blockHeight, d.chain.ChainID(), d.log), nil
+	}
+	return registers.NewRemoteRegisterFileCache(blockHeight, d.chain.ChainID(), d.log)
+}
+
+func (d *TransactionDebugger) getTransactionBlockHeight(ctx context.Context) (uint64, error) {
+	blockHeight, err := d.backend.GetTransactionBlockHeight(ctx, d.txID)
 	if err != nil {
-		d.log.Error().
-			Err(err).
-			Msg("Could not get transaction block height.")
 		return 0, err
 	}
-	blockHeight := resp.GetHeight()
 
 	d.log.Info().
 		Uint64("height", blockHeight).
@@ -230,12 +568,44 @@ func (d *TransactionDebugger) dumpTransactionToFile(body flow.TransactionBody) e
 	return err
 }
 
+type blockHeaderInfo struct {
+	ID        flow.Identifier `json:"id"`
+	Height    uint64          `json:"height"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+func (d *TransactionDebugger) dumpBlockHeaderToFile(header *flow.Header) error {
+	filename := d.directory + "/block_header.json"
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(blockHeaderInfo{
+		ID:        header.ID(),
+		Height:    header.Height,
+		Timestamp: header.Timestamp,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	d.log.Info().
+		Str("blockID", header.ID().String()).
+		Time("timestamp", header.Timestamp).
+		Msg("Got block header for transaction.")
+	return os.WriteFile(filename, data, 0644)
+}
+
 type LogInterceptor struct {
 	ComputationIntensities map[uint64]uint64 `json:"computationIntensities"`
 	MemoryIntensities      map[uint64]uint64 `json:"memoryIntensities"`
+	MemoryEstimate         uint64            `json:"memoryEstimate"`
+
+	// params are the execution parameters of the network, nil if they are not known
+	params *ExecutionParameters
 
-	log      zerolog.Logger
-	filename string
+	log       zerolog.Logger
+	directory string
 }
 
 func NewLogInterceptor(log zerolog.Logger, directory string) *LogInterceptor {
@@ -243,7 +613,7 @@ func NewLogInterceptor(log zerolog.Logger, directory string) *LogInterceptor {
 		ComputationIntensities: map[uint64]uint64{},
 		MemoryIntensities:      map[uint64]uint64{},
 		log:                    log,
-		filename:               directory + "/computation_intensities.csv",
+		directory:              directory,
 	}
 }
 
@@ -252,6 +622,7 @@ var _ io.Writer = &LogInterceptor{}
 type computationIntensitiesLog struct {
 	ComputationIntensities map[uint64]uint64 `json:"computationIntensities"`
 	MemoryIntensities      map[uint64]uint64 `json:"memoryIntensities"`
+	MemoryEstimate         uint64            `json:"memoryEstimate"`
 }
 
 func (l *LogInterceptor) Write(p []byte) (n int, err error) {
@@ -263,18 +634,77 @@ func (l *LogInterceptor) Write(p []byte) (n int, err error) {
 		}
 		l.ComputationIntensities = log.ComputationIntensities
 		l.MemoryIntensities = log.MemoryIntensities
+		l.MemoryEstimate = log.MemoryEstimate
 
 		return len(p), nil
 	}
 	return len(p), nil
 }
 
+// SetExecutionParameters sets the memory limit and the weights the intensities are weighted with.
+func (l *LogInterceptor) SetExecutionParameters(params *ExecutionParameters) {
+	l.params = params
+}
+
 func (l *LogInterceptor) Close() error {
-	err := os.MkdirAll(filepath.Dir(l.filename), os.ModePerm)
+	logEvent := l.log.Info().
+		Uint64("memoryEstimate", l.MemoryEstimate)
+	if l.params != nil && l.params.MemoryLimit != 0 {
+		logEvent = logEvent.
+			Uint64("memoryLimit", l.params.MemoryLimit).
+			Float64("memoryLimitUsedPercent", 100*float64(l.MemoryEstimate)/float64(l.params.MemoryLimit))
+	}
+	logEvent.Msg("Transaction memory estimate.")
+
+	err := l.writeIntensities(
+		l.directory+"/computation_intensities.csv",
+		"*Computation Kind",
+		l.ComputationIntensities,
+		computationKindNameMap)
 	if err != nil {
 		return err
 	}
-	csvFile, err := os.Create(l.filename)
+	err = l.writeIntensities(
+		l.directory+"/memory_intensities.csv",
+		"Memory Kind",
+		l.MemoryIntensities,
+		memoryKindNameMap)
+	if err != nil {
+		return err
+	}
+
+	if l.params == nil {
+		return nil
+	}
+	computationCost := NewCostBreakdown(
+		l.ComputationIntensities,
+		l.params.ComputationWeights,
+		1<<meter.MeterExecutionInternalPrecisionBytes,
+		computationKindNameMap)
+	computationCost.Log(l.log, "Execution effort breakdown.")
+	err = computationCost.Write(l.directory+"/computation_cost.csv", l.log)
+	if err != nil {
+		return err
+	}
+	memoryCost := NewCostBreakdown(
+		l.MemoryIntensities,
+		l.params.MemoryWeights,
+		1,
+		memoryKindNameMap)
+	return memoryCost.Write(l.directory+"/memory_cost.csv", l.log)
+}
+
+func (l *LogInterceptor) writeIntensities(
+	filename string,
+	kindHeader string,
+	intensities map[uint64]uint64,
+	kindNameMap map[uint64]string,
+) error {
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	csvFile, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
@@ -289,12 +719,12 @@ func (l *LogInterceptor) Close() error {
 
 	writer := csv.NewWriter(csvFile)
 	defer writer.Flush()
-	err = writer.Write([]string{"*Computation Kind", "Intensity"})
+	err = writer.Write([]string{kindHeader, "Intensity"})
 	if err != nil {
 		return err
 	}
-	for i, q := range l.ComputationIntensities {
-		key, ok := computationKindNameMap[i]
+	for i, q := range intensities {
+		key, ok := kindNameMap[i]
 		if !ok {
 			key = strconv.Itoa(int(i))
 		}
@@ -356,3 +786,11 @@ var computationKindNameMap = map[uint64]string{
 	2028: "ValidatePublicKey",
 	2029: "ValueExists",
 }
+
+var memoryKindNameMap = func() map[uint64]string {
+	names := make(map[uint64]string, common.MemoryKindLast)
+	for kind := common.MemoryKindUnknown; kind < common.MemoryKindLast; kind++ {
+		names[uint64(kind)] = kind.String()
+	}
+	return names
+}()